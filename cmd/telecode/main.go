@@ -92,4 +92,5 @@ func main() {
 	// Wait for shutdown signal
 	<-ctx.Done()
 	fmt.Println("\n👋 Shutting down...")
+	manager.Shutdown(cfg.ShutdownTimeout)
 }
@@ -1,43 +1,217 @@
 package bot
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"telecode/internal/config"
 	"telecode/internal/executor"
 	"telecode/internal/session"
 )
 
 // ChatSettings stores per-chat configuration
 type ChatSettings struct {
-	CLI string `json:"cli"`
+	CLI     string `json:"cli"`
+	Model   string `json:"model,omitempty"`
+	WorkDir string `json:"work_dir,omitempty"` // Set with /cd
+	Collect *bool  `json:"collect,omitempty"`  // Set with /collect, nil uses the workspace default
+	Verbose bool   `json:"verbose,omitempty"`  // Set with /verbose
+	DryRun  bool   `json:"dry_run,omitempty"`  // Set with /dryrun
+	Lang    string `json:"lang,omitempty"`     // Set with /lang, empty is English
+	Greeted bool   `json:"greeted,omitempty"`  // The /start welcome was shown
 }
 
 // Bot handles the core logic of the Telegram bot
 type Bot struct {
-	sessionMgr   *session.Manager
-	chatSettings map[int64]ChatSettings
-	settingsMu   sync.RWMutex
-	allowedChats map[int64]bool
-	executors    map[string]executor.Executor
-	defaultCLI   string
-	model        string
+	workspace     string // Name of the workspace
+	sessionMgr    *session.Manager[sessionKey]
+	chatSettings  map[sessionKey]ChatSettings
+	settingsMu    sync.RWMutex
+	allowedChats  map[int64]bool
+	allowedUsers  map[int64]bool
+	adminUsers    map[int64]bool
+	executors     map[string]executor.Executor
+	defaultCLI    string
+	model         string
+	allowedModels []string
+	running       map[sessionKey]*runningCommand
+	runningMu     sync.Mutex
+	dirRuns       map[string][]*cliRun // CLI runs in progress per working directory
+	dirRunsMu     sync.Mutex
+	sessionFile   string
+	saveMu        sync.Mutex
+	queues        map[sessionKey]*chatQueue
+	queueMu       sync.Mutex
+	queueSize     int
+	history       map[sessionKey]*historyRing
+	historyMu     sync.Mutex
+	historySize   int
+	lastPrompts   map[sessionKey]lastPrompt
+	lastOutputs   map[sessionKey]string
+	tasks         map[sessionKey][]Task // Background tasks started with /bg
+	lastTaskID    int
+	tasksMu       sync.Mutex
+	lastActivity  map[sessionKey]time.Time // When the chat last ran a prompt
+	activityMu    sync.Mutex
+	idleTimeout   time.Duration // 0 keeps sessions forever
+	chatStats     map[sessionKey]ChatStats
+	statsMu       sync.Mutex
+	limiter       *rateLimiter // nil when prompts aren't rate limited
+	cache         *outputCache // nil when replies aren't cached
+	systemPrompt  string
+	extraArgs     map[string][]string // Per CLI
+	workingDir    string
+	allowedRoot   string          // /cd can't leave this directory
+	collectMode   bool            // Default for /collect
+	cliPrefix     string          // Runs a single prompt with another CLI, as in "!opencode"
+	disabled      map[string]bool // Commands the workspace turned off
 }
 
-// NewBot creates a new bot instance
-func NewBot(allowedChats map[int64]bool, defaultCLI string, model string) *Bot {
+// sessionState is the on-disk representation of sessions and chat settings
+type sessionState struct {
+	Sessions map[sessionKey]string           `json:"sessions"`
+	Settings map[sessionKey]ChatSettings     `json:"settings"`
+	History  map[sessionKey][]session.Record `json:"history,omitempty"`
+}
+
+// runningCommand tracks a CLI invocation in progress for a chat
+type runningCommand struct {
+	cancel  context.CancelFunc
+	started time.Time
+}
+
+// cliRun is a CLI invocation in a working directory. Runs are tracked per
+// directory, as a session found in the CLI's session store may belong to any
+// run that was active there at the same time.
+type cliRun struct {
+	dir     string
+	started time.Time
+	shared  bool // Another run was active in dir meanwhile, guarded by dirRunsMu
+}
+
+// NewBot creates a new bot instance for a workspace
+func NewBot(cfg config.WorkspaceConfig) *Bot {
+	// Convert allowlists to maps
+	allowedChats := make(map[int64]bool)
+	for _, chatID := range cfg.AllowedChats {
+		allowedChats[chatID] = true
+	}
+	allowedUsers := make(map[int64]bool)
+	for _, userID := range cfg.AllowedUsers {
+		allowedUsers[userID] = true
+	}
+	adminUsers := make(map[int64]bool)
+	for _, userID := range cfg.AdminUsers {
+		adminUsers[userID] = true
+	}
+
+	// Executables configured instead of a CLI's default one
+	binaries := map[string]string{"gemini": cfg.GeminiBinary}
+
+	// Only CLIs in the allowlist are ever executed
+	executors := make(map[string]executor.Executor)
+	for _, cli := range cfg.AllowedCLIs {
+		exec, ok := executor.Lookup(cli)
+		if !ok {
+			fmt.Printf("⚠️ Workspace %s: allowed_clis contains unknown CLI '%s', ignoring\n", cfg.Name, cli)
+			continue
+		}
+		if configurer, ok := exec.(executor.BinaryConfigurer); ok && binaries[cli] != "" {
+			configurer.SetBinary(binaries[cli])
+		}
+		executors[cli] = exec
+	}
+
+	// Extra arguments are passed as configured, but overriding flags
+	// telecode sets itself likely breaks sessions or output parsing
+	for cli, args := range cfg.ExtraArgs {
+		exec := executors[cli]
+		if exec == nil {
+			fmt.Printf("⚠️ Workspace %s: extra_args for unknown CLI '%s' are ignored\n", cfg.Name, cli)
+			continue
+		}
+		for _, arg := range executor.CollidingArgs(exec, args) {
+			fmt.Printf("⚠️ Workspace %s: extra_args for %s sets %s, which telecode manages\n", cfg.Name, cli, arg)
+		}
+	}
+
 	return &Bot{
-		sessionMgr:   session.NewManager(),
-		chatSettings: make(map[int64]ChatSettings),
-		allowedChats: allowedChats,
-		executors: map[string]executor.Executor{
-			"claude":   &executor.ClaudeExecutor{},
-			"opencode": &executor.OpenCodeExecutor{},
-		},
-		defaultCLI: defaultCLI,
-		model:      model,
+		workspace:     cfg.Name,
+		sessionMgr:    session.NewManager[sessionKey](),
+		chatSettings:  make(map[sessionKey]ChatSettings),
+		allowedChats:  allowedChats,
+		allowedUsers:  allowedUsers,
+		adminUsers:    adminUsers,
+		executors:     executors,
+		defaultCLI:    cfg.DefaultCLI,
+		model:         cfg.DefaultModel,
+		allowedModels: cfg.AllowedModels,
+		running:       make(map[sessionKey]*runningCommand),
+		dirRuns:       make(map[string][]*cliRun),
+		sessionFile:   cfg.SessionFile,
+		queues:        make(map[sessionKey]*chatQueue),
+		queueSize:     cfg.QueueSize,
+		history:       make(map[sessionKey]*historyRing),
+		historySize:   cfg.HistorySize,
+		lastPrompts:   make(map[sessionKey]lastPrompt),
+		lastOutputs:   make(map[sessionKey]string),
+		tasks:         make(map[sessionKey][]Task),
+		lastActivity:  make(map[sessionKey]time.Time),
+		idleTimeout:   cfg.SessionIdleTimeout,
+		chatStats:     make(map[sessionKey]ChatStats),
+		limiter:       newRateLimiter(cfg.RateLimit, cfg.RateLimitWindow),
+		cache:         newOutputCache(cfg.CacheTTL, cfg.CacheMaxEntries),
+		systemPrompt:  strings.TrimSpace(cfg.SystemPrompt),
+		extraArgs:     cfg.ExtraArgs,
+		workingDir:    cfg.WorkingDir,
+		allowedRoot:   cfg.AllowedRoot,
+		collectMode:   cfg.CollectMode,
+		cliPrefix:     cfg.CLIPrefix,
+		disabled:      disabledCommands(cfg),
+	}
+}
+
+// disabledCommands returns the commands turned off in cfg. Commands in
+// optInCommands are off unless enabled.
+func disabledCommands(cfg config.WorkspaceConfig) map[string]bool {
+	disabled := make(map[string]bool)
+	for _, cmd := range optInCommands {
+		disabled[cmd] = true
 	}
+	if cfg.AllowGit {
+		delete(disabled, "/commit")
+	}
+	if cfg.AllowCompare {
+		delete(disabled, "/compare")
+	}
+	for _, cmd := range cfg.EnabledCommands {
+		if !isKnownCommand(cmd) {
+			fmt.Printf("⚠️ Workspace %s: enabled_commands contains unknown command '%s', ignoring\n", cfg.Name, cmd)
+		}
+		delete(disabled, cmd)
+	}
+	for _, cmd := range cfg.DisabledCommands {
+		if !isKnownCommand(cmd) {
+			fmt.Printf("⚠️ Workspace %s: disabled_commands contains unknown command '%s', ignoring\n", cfg.Name, cmd)
+			continue
+		}
+		disabled[cmd] = true
+	}
+	return disabled
+}
+
+// CommandEnabled reports whether the workspace allows the command
+func (b *Bot) CommandEnabled(cmd string) bool {
+	return !b.disabled[cmd]
 }
 
 // IsAllowed checks if the chat_id is in the allowlist
@@ -45,55 +219,324 @@ func (b *Bot) IsAllowed(chatID int64) bool {
 	return b.allowedChats[chatID]
 }
 
+// IsAuthorized checks if the user is in the user allowlist.
+// An empty allowlist authorizes every user.
+func (b *Bot) IsAuthorized(userID int64) bool {
+	if len(b.allowedUsers) == 0 {
+		return true
+	}
+	return b.allowedUsers[userID]
+}
+
+// IsInlineAllowed checks if the user may send inline queries. Inline
+// queries have no chat, so with an empty user allowlist the user's private
+// chat has to be allowed.
+func (b *Bot) IsInlineAllowed(userID int64) bool {
+	if len(b.allowedUsers) > 0 {
+		return b.allowedUsers[userID]
+	}
+	return b.allowedChats[userID]
+}
+
+// IsAdmin checks if the user may run admin commands. Unlike the user
+// allowlist, an empty admin list allows nobody.
+func (b *Bot) IsAdmin(userID int64) bool {
+	return b.adminUsers[userID]
+}
+
+// AllowPrompt checks the user's prompt rate limit. If it was reached, it
+// returns false and how long the user has to wait.
+func (b *Bot) AllowPrompt(userID int64) (bool, time.Duration) {
+	return b.limiter.Allow(userID)
+}
+
+// CachedReply returns the reply cached for prompt in scope and its age, or
+// false if there is none
+func (b *Bot) CachedReply(scope cacheScope, prompt string) (string, time.Duration, bool) {
+	return b.cache.Get(cacheKey(b.workspace, scope, prompt))
+}
+
+// CacheReply caches the reply to prompt in scope
+func (b *Bot) CacheReply(scope cacheScope, prompt, reply string) {
+	b.cache.Put(cacheKey(b.workspace, scope, prompt), reply)
+}
+
+// SystemPrompt returns the workspace's system prompt, empty if none is set
+func (b *Bot) SystemPrompt() string {
+	return b.systemPrompt
+}
+
 // GetCLI returns the CLI setting for a chat
-func (b *Bot) GetCLI(chatID int64) string {
+func (b *Bot) GetCLI(key sessionKey) string {
 	b.settingsMu.RLock()
 	defer b.settingsMu.RUnlock()
-	if cli := b.chatSettings[chatID].CLI; cli != "" {
+	if cli := b.chatSettings[key].CLI; cli != "" {
 		return cli
 	}
 	return b.defaultCLI
 }
 
 // SetCLI sets the CLI for a chat
-func (b *Bot) SetCLI(chatID int64, cli string) error {
+func (b *Bot) SetCLI(key sessionKey, cli string) error {
+	if b.executors[cli] == nil {
+		return fmt.Errorf("CLI '%s' is not allowed", cli)
+	}
+
 	// Check if CLI exists
-	if _, err := exec.LookPath(cli); err != nil {
+	if _, err := exec.LookPath(b.executors[cli].Binary()); err != nil {
 		return fmt.Errorf("CLI '%s' is not installed", cli)
 	}
 
 	b.settingsMu.Lock()
-	defer b.settingsMu.Unlock()
-	settings := b.chatSettings[chatID]
+	settings := b.chatSettings[key]
 	settings.CLI = cli
-	b.chatSettings[chatID] = settings
+	// Model names are CLI specific
+	settings.Model = ""
+	b.chatSettings[key] = settings
 
 	// Reset session when CLI changes
-	b.sessionMgr.Delete(chatID)
+	b.sessionMgr.Delete(key)
+	b.settingsMu.Unlock()
 
+	b.persistSessions()
 	return nil
 }
 
+// GetModel returns the model for a chat. The workspace model only applies
+// while the chat uses the workspace's default CLI; an empty result lets the
+// CLI pick its own default.
+func (b *Bot) GetModel(key sessionKey) string {
+	b.settingsMu.RLock()
+	settings := b.chatSettings[key]
+	b.settingsMu.RUnlock()
+
+	if settings.Model != "" {
+		return settings.Model
+	}
+	if settings.CLI == "" || settings.CLI == b.defaultCLI {
+		return b.model
+	}
+	return ""
+}
+
+// SetModel sets the model for a chat; an empty model restores the default
+func (b *Bot) SetModel(key sessionKey, model string) error {
+	if model != "" && !b.IsModelAllowed(model) {
+		return fmt.Errorf("unknown model '%s'", model)
+	}
+
+	b.settingsMu.Lock()
+	settings := b.chatSettings[key]
+	settings.Model = model
+	b.chatSettings[key] = settings
+	b.settingsMu.Unlock()
+
+	b.persistSessions()
+	return nil
+}
+
+// IsVerbose reports whether the chat shows the command, duration and exit
+// code of each run
+func (b *Bot) IsVerbose(key sessionKey) bool {
+	b.settingsMu.RLock()
+	defer b.settingsMu.RUnlock()
+	return b.chatSettings[key].Verbose
+}
+
+// SetVerbose turns verbose output on or off for a chat
+func (b *Bot) SetVerbose(key sessionKey, on bool) {
+	b.settingsMu.Lock()
+	settings := b.chatSettings[key]
+	settings.Verbose = on
+	b.chatSettings[key] = settings
+	b.settingsMu.Unlock()
+
+	b.persistSessions()
+}
+
+// IsDryRun reports whether prompts in the conversation only show the command
+// they would run
+func (b *Bot) IsDryRun(key sessionKey) bool {
+	b.settingsMu.RLock()
+	defer b.settingsMu.RUnlock()
+	return b.chatSettings[key].DryRun
+}
+
+// SetDryRun turns dry-run mode on or off for a conversation
+func (b *Bot) SetDryRun(key sessionKey, on bool) {
+	b.settingsMu.Lock()
+	settings := b.chatSettings[key]
+	settings.DryRun = on
+	b.chatSettings[key] = settings
+	b.settingsMu.Unlock()
+
+	b.persistSessions()
+}
+
+// MarkGreeted records that the conversation was shown the welcome message
+// and reports whether that is the first time
+func (b *Bot) MarkGreeted(key sessionKey) bool {
+	b.settingsMu.Lock()
+	settings := b.chatSettings[key]
+	first := !settings.Greeted
+	settings.Greeted = true
+	b.chatSettings[key] = settings
+	b.settingsMu.Unlock()
+
+	if first {
+		b.persistSessions()
+	}
+	return first
+}
+
+// IsModelAllowed checks if the model is in the model allowlist.
+// An empty allowlist allows any model.
+func (b *Bot) IsModelAllowed(model string) bool {
+	if len(b.allowedModels) == 0 {
+		return true
+	}
+	for _, allowed := range b.allowedModels {
+		if allowed == model {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedModels returns the models selectable with /model
+func (b *Bot) AllowedModels() []string {
+	return b.allowedModels
+}
+
 // GetSessionID returns the session ID for a chat
-func (b *Bot) GetSessionID(chatID int64) string {
-	return b.sessionMgr.Get(chatID)
+func (b *Bot) GetSessionID(key sessionKey) string {
+	return b.sessionMgr.Get(key)
 }
 
-// NewSession starts a new session
-func (b *Bot) NewSession(chatID int64) {
-	b.sessionMgr.Delete(chatID)
+// NewSession starts a new session with the workspace's default CLI and model
+func (b *Bot) NewSession(key sessionKey) {
+	b.settingsMu.Lock()
+	settings := b.chatSettings[key]
+	cliChanged := settings.CLI != "" && settings.CLI != b.defaultCLI
+	settings.CLI = ""
+	settings.Model = ""
+	b.chatSettings[key] = settings
+
+	if cliChanged {
+		// Sessions of the previous CLI can't be resumed with the default one
+		b.sessionMgr.Delete(key)
+	} else {
+		b.sessionMgr.Discard(key)
+	}
+	b.settingsMu.Unlock()
+
+	b.persistSessions()
+}
+
+// ClearSession deletes the session along with the previous one and the
+// session history, so the next message starts without any previous context
+// and /undo has nothing to restore
+func (b *Bot) ClearSession(key sessionKey) {
+	b.sessionMgr.Delete(key)
+	b.persistSessions()
 }
 
-// UpdateSessionFromOutput extracts and saves session ID from output
-func (b *Bot) UpdateSessionFromOutput(chatID int64, cli, output string) {
+// ExpireIdleSession records a prompt of the chat and starts a new session if
+// the previous prompt is older than session_idle_timeout, reporting whether
+// it did. Activity is kept in memory, so it is counted from the bot's start.
+func (b *Bot) ExpireIdleSession(key sessionKey) bool {
+	now := time.Now()
+	b.activityMu.Lock()
+	last, seen := b.lastActivity[key]
+	b.lastActivity[key] = now
+	b.activityMu.Unlock()
+
+	if b.idleTimeout <= 0 || !seen || now.Sub(last) < b.idleTimeout || b.GetSessionID(key) == "" {
+		return false
+	}
+	b.NewSession(key)
+	return true
+}
+
+// UndoSession restores the chat's session from before the last reset or
+// change, returning its ID or false if there is none
+func (b *Bot) UndoSession(key sessionKey) (string, bool) {
+	sessionID, ok := b.sessionMgr.Undo(key)
+	if ok {
+		b.persistSessions()
+	}
+	return sessionID, ok
+}
+
+// SessionHistory returns the sessions the chat used, most recent first
+func (b *Bot) SessionHistory(key sessionKey) []session.Record {
+	return b.sessionMgr.History(key)
+}
+
+// ResumeSession switches the chat to a session it used before, given its ID
+// or a unique prefix of it, and returns the full ID
+func (b *Bot) ResumeSession(key sessionKey, id string) (string, error) {
+	sessionID, ok := b.sessionMgr.Resume(key, id)
+	if !ok {
+		return "", fmt.Errorf("unknown session '%s', see /sessions", id)
+	}
+	b.persistSessions()
+	return sessionID, nil
+}
+
+// UpdateSessionFromOutput extracts and saves session ID from the output of
+// run, a command that was started with prevSessionID, and records its token
+// usage. The output is ignored if the chat switched CLI or session while the
+// command was running, so a late result never overwrites a newer session. If
+// no session ID can be parsed, the CLI's session store is searched, unless
+// other runs were active in the same directory and could have written the
+// session found; lost reports that this failed too, so the run's context is
+// lost.
+func (b *Bot) UpdateSessionFromOutput(key sessionKey, cli, prevSessionID, output string, run *cliRun) (lost bool) {
 	exec := b.executors[cli]
 	if exec == nil {
-		return
+		return false
+	}
+
+	// Usage counts even if the chat moved on in the meantime
+	if usageParser, ok := exec.(executor.UsageParser); ok {
+		if usage, ok := usageParser.ParseUsage(output); ok {
+			b.recordUsage(key, usage)
+		}
 	}
 
-	if sessionID := exec.ParseSessionID(output); sessionID != "" {
-		b.sessionMgr.Set(chatID, sessionID)
+	if b.GetCLI(key) != cli {
+		return false
 	}
+
+	parser := exec.SessionParser()
+	if parser == nil {
+		return false // The CLI keeps no sessions
+	}
+	sessionID, ok := parser.ParseSessionID(output)
+	if !ok && strings.TrimSpace(output) == "" {
+		return false // The CLI didn't run
+	}
+	if !ok {
+		if finder, isFinder := exec.(executor.SessionFinder); isFinder && !b.isSharedRun(run) {
+			sessionID, ok = finder.FindSession(run.dir, run.started)
+		}
+		if ok {
+			fmt.Printf("⚠️ Could not parse %s session ID for chat %s, using session %q from the CLI's session store\n", cli, key, sessionID)
+		}
+	}
+	if !ok {
+		// Keep the prior session rather than losing the conversation
+		fmt.Printf("⚠️ Could not parse %s session ID for chat %s, keeping session %q\n", cli, key, prevSessionID)
+		return true
+	}
+	if sessionID == prevSessionID {
+		return false
+	}
+	if b.sessionMgr.CompareAndSet(key, prevSessionID, sessionID) {
+		b.persistSessions()
+	}
+	return false
 }
 
 // GetExecutor returns the Executor for a CLI name
@@ -101,22 +544,98 @@ func (b *Bot) GetExecutor(cli string) executor.Executor {
 	return b.executors[cli]
 }
 
-// BuildCommand builds the CLI command
-func (b *Bot) BuildCommand(chatID int64, prompt, imagePath string) []string {
-	cli := b.GetCLI(chatID)
-	sessionID := b.GetSessionID(chatID)
+// SupportedCLIs returns the names of the CLIs the workspace allows, sorted
+func (b *Bot) SupportedCLIs() []string {
+	clis := make([]string, 0, len(b.executors))
+	for cli := range b.executors {
+		clis = append(clis, cli)
+	}
+	sort.Strings(clis)
+	return clis
+}
+
+// buildCommand builds the command for req. The prompt is written to stdin
+// instead of passed as an argument if the CLI supports it.
+func buildCommand(exec executor.Executor, req executor.Request) (cmd []string, stdin string) {
+	if prompter, ok := exec.(executor.StdinPrompter); ok {
+		return prompter.BuildStdinCommand(req)
+	}
+	return exec.BuildCommand(req), ""
+}
+
+// BuildCommand builds the CLI command and the input to write to its stdin
+func (b *Bot) BuildCommand(key sessionKey, prompt string, filePaths []string) (cmd []string, stdin string) {
+	cli := b.GetCLI(key)
+	sessionID := b.GetSessionID(key)
 
 	exec := b.executors[cli]
 	if exec == nil {
-		return nil
+		return nil, ""
+	}
+
+	return buildCommand(exec, executor.Request{
+		Prompt:       prompt,
+		SessionID:    sessionID,
+		FilePaths:    filePaths,
+		Model:        b.GetModel(key),
+		SystemPrompt: b.systemPrompt,
+		ExtraArgs:    b.extraArgs[cli],
+	})
+}
+
+// BuildOneOffCommand builds a command for cli, or the workspace's default
+// CLI if cli is empty, that starts without a session, as used by inline
+// queries, background tasks and prompts with a CLI prefix. An empty model
+// leaves the choice to the CLI.
+func (b *Bot) BuildOneOffCommand(cli, model, prompt string, filePaths []string) (string, []string, string) {
+	if cli == "" {
+		cli = b.defaultCLI
+	}
+	exec := b.executors[cli]
+	if exec == nil {
+		return cli, nil, ""
+	}
+
+	cmd, stdin := buildCommand(exec, executor.Request{
+		Prompt:       prompt,
+		FilePaths:    filePaths,
+		Model:        model,
+		SystemPrompt: b.systemPrompt,
+		ExtraArgs:    b.extraArgs[cli],
+	})
+	return cli, cmd, stdin
+}
+
+// OneOffModel returns the model of one-off commands for cli: the workspace
+// model for the default CLI, and the CLI's own default otherwise
+func (b *Bot) OneOffModel(cli string) string {
+	if cli == b.defaultCLI {
+		return b.model
 	}
+	return ""
+}
 
-	return exec.BuildCommand(prompt, sessionID, imagePath, b.model)
+// CLIOverride splits a leading CLI prefix such as "!opencode" off prompt and
+// returns the CLI it names and the rest of the prompt. cli is empty if the
+// prompt doesn't start with the name of a CLI, and err is set if it names one
+// the workspace doesn't allow.
+func (b *Bot) CLIOverride(prompt string) (cli, rest string, err error) {
+	if b.cliPrefix == "" || !strings.HasPrefix(prompt, b.cliPrefix) {
+		return "", prompt, nil
+	}
+	name, rest, _ := strings.Cut(strings.TrimPrefix(prompt, b.cliPrefix), " ")
+	if !slices.Contains(executor.Names(), name) {
+		return "", prompt, nil // Just a prompt starting with the prefix
+	}
+	if b.executors[name] == nil {
+		return "", "", fmt.Errorf("CLI '%s' is not allowed", name)
+	}
+	return name, strings.TrimSpace(rest), nil
 }
 
 // GetStats returns statistics for current CLI
-func (b *Bot) GetStats(chatID int64) (string, error) {
-	cli := b.GetCLI(chatID)
+func (b *Bot) GetStats(key sessionKey) (string, error) {
+	cli := b.GetCLI(key)
 	exec := b.executors[cli]
 	if exec == nil {
 		return "", fmt.Errorf("unsupported CLI: %s", cli)
@@ -125,9 +644,9 @@ func (b *Bot) GetStats(chatID int64) (string, error) {
 }
 
 // GetStatus returns the current status
-func (b *Bot) GetStatus(chatID int64) (cli, sessionID string) {
-	cli = b.GetCLI(chatID)
-	sessionID = b.GetSessionID(chatID)
+func (b *Bot) GetStatus(key sessionKey) (cli, sessionID string) {
+	cli = b.GetCLI(key)
+	sessionID = b.GetSessionID(key)
 
 	if sessionID == "" {
 		sessionID = "none"
@@ -135,3 +654,140 @@ func (b *Bot) GetStatus(chatID int64) (cli, sessionID string) {
 
 	return
 }
+
+// StartRun registers a cancelable context for a command running in a chat.
+// The returned function must be called once the command has finished.
+func (b *Bot) StartRun(ctx context.Context, key sessionKey) (context.Context, func()) {
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &runningCommand{cancel: cancel, started: time.Now()}
+
+	b.runningMu.Lock()
+	b.running[key] = run
+	b.runningMu.Unlock()
+
+	return runCtx, func() {
+		b.runningMu.Lock()
+		if b.running[key] == run {
+			delete(b.running, key)
+		}
+		b.runningMu.Unlock()
+		cancel()
+	}
+}
+
+// TrackRun registers a CLI run starting in dir. The returned function must be
+// called once the run's session was updated.
+func (b *Bot) TrackRun(dir string) (*cliRun, func()) {
+	run := &cliRun{dir: dir, started: time.Now()}
+
+	b.dirRunsMu.Lock()
+	for _, other := range b.dirRuns[dir] {
+		other.shared = true
+		run.shared = true
+	}
+	b.dirRuns[dir] = append(b.dirRuns[dir], run)
+	b.dirRunsMu.Unlock()
+
+	return run, func() {
+		b.dirRunsMu.Lock()
+		defer b.dirRunsMu.Unlock()
+		runs := slices.DeleteFunc(b.dirRuns[dir], func(r *cliRun) bool { return r == run })
+		if len(runs) == 0 {
+			delete(b.dirRuns, dir)
+		} else {
+			b.dirRuns[dir] = runs
+		}
+	}
+}
+
+// isSharedRun reports whether another run was active in the directory of run
+// while it was tracked
+func (b *Bot) isSharedRun(run *cliRun) bool {
+	b.dirRunsMu.Lock()
+	defer b.dirRunsMu.Unlock()
+	return run.shared
+}
+
+// CancelRun cancels the command running in a chat, reporting whether there was one
+func (b *Bot) CancelRun(key sessionKey) bool {
+	b.runningMu.Lock()
+	defer b.runningMu.Unlock()
+	run, ok := b.running[key]
+	if !ok {
+		return false
+	}
+	run.cancel()
+	delete(b.running, key)
+	return true
+}
+
+// SaveSessions writes sessions and chat settings to the session file.
+// The file is replaced atomically so a crash never leaves it half written.
+func (b *Bot) SaveSessions() error {
+	if b.sessionFile == "" {
+		return nil
+	}
+
+	b.saveMu.Lock()
+	defer b.saveMu.Unlock()
+
+	b.settingsMu.RLock()
+	state := sessionState{
+		Sessions: b.sessionMgr.All(),
+		History:  b.sessionMgr.AllHistory(),
+		Settings: make(map[sessionKey]ChatSettings, len(b.chatSettings)),
+	}
+	for chatID, settings := range b.chatSettings {
+		state.Settings[chatID] = settings
+	}
+	b.settingsMu.RUnlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sessions: %w", err)
+	}
+
+	if err := writeFileAtomic(b.sessionFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write session file: %w", err)
+	}
+	return nil
+}
+
+// LoadSessions restores sessions and chat settings from the session file.
+// A missing file is not an error.
+func (b *Bot) LoadSessions() error {
+	if b.sessionFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(b.sessionFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read session file: %w", err)
+	}
+
+	var state sessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse session file: %w", err)
+	}
+
+	b.sessionMgr.Replace(state.Sessions)
+	b.sessionMgr.ReplaceHistory(state.History)
+
+	b.settingsMu.Lock()
+	defer b.settingsMu.Unlock()
+	b.chatSettings = make(map[sessionKey]ChatSettings, len(state.Settings))
+	for chatID, settings := range state.Settings {
+		b.chatSettings[chatID] = settings
+	}
+	return nil
+}
+
+// persistSessions saves sessions, logging instead of failing on errors
+func (b *Bot) persistSessions() {
+	if err := b.SaveSessions(); err != nil {
+		fmt.Printf("⚠️ Failed to save sessions: %v\n", err)
+	}
+}
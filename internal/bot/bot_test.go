@@ -0,0 +1,277 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"telecode/internal/config"
+)
+
+// TestConcurrentSessionAccess is meant to be run with -race
+func TestConcurrentSessionAccess(t *testing.T) {
+	installFakeCLI(t, "claude")
+	installFakeCLI(t, "opencode")
+	ws, _ := newTestWorkspace(t, config.WorkspaceConfig{
+		SessionFile: filepath.Join(t.TempDir(), "sessions.json"),
+	})
+	b := ws.Bot
+	clis := []string{"claude", "opencode"}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				// Share some chats between goroutines
+				key := sessionKey{chatID: int64(i % 3)}
+				switch i % 4 {
+				case 0:
+					if err := b.SetCLI(key, clis[(g+i)%2]); err != nil {
+						t.Error(err)
+					}
+				case 1:
+					b.NewSession(key)
+				case 2:
+					b.sessionMgr.Set(key, fmt.Sprintf("session-%d-%d", g, i))
+				default:
+					cli, _ := b.GetStatus(key)
+					if cli != "claude" && cli != "opencode" {
+						t.Errorf("GetStatus returned CLI %q", cli)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClearSessionLeavesNothingToUndo(t *testing.T) {
+	ws, _ := newTestWorkspace(t, config.WorkspaceConfig{})
+	b := ws.Bot
+	key := sessionKey{chatID: 1}
+
+	b.sessionMgr.Set(key, "first")
+	b.NewSession(key)
+	if id, ok := b.UndoSession(key); !ok || id != "first" {
+		t.Fatalf("UndoSession after NewSession = %q, %v, want \"first\", true", id, ok)
+	}
+
+	b.ClearSession(key)
+	if id := b.GetSessionID(key); id != "" {
+		t.Errorf("session after ClearSession = %q, want none", id)
+	}
+	if id, ok := b.UndoSession(key); ok {
+		t.Errorf("UndoSession after ClearSession restored %q", id)
+	}
+	if history := b.sessionMgr.History(key); len(history) != 0 {
+		t.Errorf("ClearSession kept %d history records", len(history))
+	}
+}
+
+func TestUpdateSessionFromOutput(t *testing.T) {
+	// FindSession must not find sessions of the real claude config
+	t.Setenv("CLAUDE_CONFIG_DIR", t.TempDir())
+
+	tests := []struct {
+		name     string
+		cli      string
+		output   string
+		want     string
+		wantLost bool
+	}{
+		{
+			name:   "claude",
+			cli:    "claude",
+			output: `{"type":"system","subtype":"init","session_id":"new-session"}` + "\n" + `{"type":"result","result":"ok","session_id":"new-session"}`,
+			want:   "new-session",
+		},
+		{
+			name:   "opencode",
+			cli:    "opencode",
+			output: `{"type":"step_start","sessionID":"ses_new","part":{"sessionID":"ses_new"}}`,
+			want:   "ses_new",
+		},
+		{
+			name:     "unparsable",
+			cli:      "claude",
+			output:   "Error: Invalid API key",
+			want:     "prior",
+			wantLost: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installFakeCLI(t, tt.cli)
+			ws, _ := newTestWorkspace(t, config.WorkspaceConfig{})
+			key := sessionKey{chatID: 1}
+			if err := ws.Bot.SetCLI(key, tt.cli); err != nil {
+				t.Fatal(err)
+			}
+			ws.Bot.sessionMgr.Set(key, "prior")
+
+			run, finish := ws.Bot.TrackRun(ws.Config.WorkingDir)
+			defer finish()
+			lost := ws.Bot.UpdateSessionFromOutput(key, tt.cli, "prior", tt.output, run)
+			if lost != tt.wantLost {
+				t.Errorf("lost = %v, want %v", lost, tt.wantLost)
+			}
+			if got := ws.Bot.GetSessionID(key); got != tt.want {
+				t.Errorf("session = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateSessionFromOutputSessionStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		sessions []string
+		shared   bool
+		want     string
+	}{
+		{name: "one new session", sessions: []string{"found"}, want: "found"},
+		{name: "several new sessions", sessions: []string{"a", "b"}, want: "prior"},
+		{name: "another run in the directory", sessions: []string{"other"}, shared: true, want: "prior"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configDir := t.TempDir()
+			t.Setenv("CLAUDE_CONFIG_DIR", configDir)
+			ws, _ := newTestWorkspace(t, config.WorkspaceConfig{})
+			key := sessionKey{chatID: 1}
+			ws.Bot.sessionMgr.Set(key, "prior")
+
+			run, finish := ws.Bot.TrackRun(ws.Config.WorkingDir)
+			defer finish()
+			if tt.shared {
+				_, finishOther := ws.Bot.TrackRun(ws.Config.WorkingDir)
+				finishOther()
+			}
+
+			// Claude Code names the project directory after the working dir
+			project := strings.Map(func(r rune) rune {
+				if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+					return r
+				}
+				return '-'
+			}, ws.Config.WorkingDir)
+			dir := filepath.Join(configDir, "projects", project)
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			// File times are coarser than the clock, so keep them clearly after the start
+			written := run.started.Add(time.Second)
+			for _, id := range tt.sessions {
+				path := filepath.Join(dir, id+".jsonl")
+				if err := os.WriteFile(path, nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, written, written); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			lost := ws.Bot.UpdateSessionFromOutput(key, "claude", "prior", "Error: connection reset", run)
+			if got := ws.Bot.GetSessionID(key); got != tt.want {
+				t.Errorf("session = %q, want %q", got, tt.want)
+			}
+			if wantLost := tt.want == "prior"; lost != wantLost {
+				t.Errorf("lost = %v, want %v", lost, wantLost)
+			}
+		})
+	}
+}
+
+func TestBuildCommandLongPromptUsesStdin(t *testing.T) {
+	prompt := strings.Repeat("x", 200*1024)
+	for _, cli := range []string{"claude", "opencode", "gemini"} {
+		t.Run(cli, func(t *testing.T) {
+			ws, _ := newTestWorkspace(t, config.WorkspaceConfig{DefaultCLI: cli, AllowedCLIs: []string{cli}})
+
+			cmd, stdin := ws.Bot.BuildCommand(sessionKey{chatID: 1}, prompt, nil)
+			if size := len(strings.Join(cmd, " ")); size > 1024 {
+				t.Errorf("arguments are %d bytes, want the prompt outside of them", size)
+			}
+			if stdin != prompt {
+				t.Errorf("stdin is %d bytes, want the %d of the prompt", len(stdin), len(prompt))
+			}
+		})
+	}
+}
+
+func TestNewSessionRestoresDefaults(t *testing.T) {
+	installFakeCLI(t, "opencode")
+	ws, _ := newTestWorkspace(t, config.WorkspaceConfig{DefaultModel: "sonnet"})
+	b := ws.Bot
+	key := sessionKey{chatID: 1}
+
+	// A model chosen with /model is dropped, the session can still be undone
+	b.sessionMgr.Set(key, "first")
+	if err := b.SetModel(key, "opus"); err != nil {
+		t.Fatal(err)
+	}
+	b.NewSession(key)
+	if cli, model := b.GetCLI(key), b.GetModel(key); cli != "claude" || model != "sonnet" {
+		t.Errorf("after NewSession CLI, model = %q, %q, want the defaults claude, sonnet", cli, model)
+	}
+	if _, ok := b.UndoSession(key); !ok {
+		t.Error("the previous session of the same CLI can't be undone")
+	}
+
+	// Sessions of another CLI can't be resumed once the default CLI is back
+	if err := b.SetCLI(key, "opencode"); err != nil {
+		t.Fatal(err)
+	}
+	b.sessionMgr.Set(key, "ses_1")
+	b.NewSession(key)
+	if cli, model := b.GetCLI(key), b.GetModel(key); cli != "claude" || model != "sonnet" {
+		t.Errorf("after NewSession CLI, model = %q, %q, want the defaults claude, sonnet", cli, model)
+	}
+	if id, ok := b.UndoSession(key); ok {
+		t.Errorf("UndoSession restored opencode session %q for claude", id)
+	}
+}
+
+// TestExpireIdleSession is meant to be run with -race
+func TestExpireIdleSession(t *testing.T) {
+	ws, _ := newTestWorkspace(t, config.WorkspaceConfig{SessionIdleTimeout: time.Hour})
+	b := ws.Bot
+	key := sessionKey{chatID: 1}
+	b.sessionMgr.Set(key, "first")
+
+	// Activity and history are tracked separately, neither blocks the other
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			b.ExpireIdleSession(key)
+		}()
+		go func() {
+			defer wg.Done()
+			b.RecordExchange(key, "prompt", "response")
+		}()
+	}
+	wg.Wait()
+	if id := b.GetSessionID(key); id != "first" {
+		t.Fatalf("session = %q after recent activity, want \"first\"", id)
+	}
+
+	b.activityMu.Lock()
+	b.lastActivity[key] = time.Now().Add(-2 * time.Hour)
+	b.activityMu.Unlock()
+	if !b.ExpireIdleSession(key) {
+		t.Error("ExpireIdleSession kept a session idle for longer than the timeout")
+	}
+	if id := b.GetSessionID(key); id != "" {
+		t.Errorf("session = %q after expiry, want none", id)
+	}
+}
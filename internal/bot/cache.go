@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// outputCache remembers the replies to prompts for a while, so a repeated
+// question is answered without running the CLI again
+type outputCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]cacheEntry
+}
+
+// cacheEntry is a cached reply
+type cacheEntry struct {
+	reply  string
+	stored time.Time
+}
+
+// newOutputCache creates a cache keeping replies for ttl, up to maxEntries of
+// them. It returns nil (no caching) when ttl or maxEntries is not positive.
+func newOutputCache(ttl time.Duration, maxEntries int) *outputCache {
+	if ttl <= 0 || maxEntries <= 0 {
+		return nil
+	}
+	return &outputCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]cacheEntry),
+	}
+}
+
+// cacheScope is what a reply depends on besides the prompt. Replies are only
+// reused within the conversation, working directory and session they were
+// given in, so an answer never reaches another chat.
+type cacheScope struct {
+	key       sessionKey
+	workDir   string
+	sessionID string
+	cli       string
+	model     string
+}
+
+// cacheKey hashes the workspace, scope and prompt of a reply into a cache key
+func cacheKey(workspace string, scope cacheScope, prompt string) string {
+	parts := []string{workspace, scope.key.String(), scope.workDir, scope.sessionID, scope.cli, scope.model, prompt}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached reply for key and how old it is, or false if there
+// is none that hasn't expired
+func (c *outputCache) Get(key string) (string, time.Duration, bool) {
+	if c == nil {
+		return "", 0, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", 0, false
+	}
+	age := time.Since(entry.stored)
+	if age > c.ttl {
+		delete(c.entries, key)
+		return "", 0, false
+	}
+	return entry.reply, age, true
+}
+
+// Put caches the reply for key. Expired entries are evicted first, then the
+// oldest ones while the cache is full.
+func (c *outputCache) Put(key, reply string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.Sub(entry.stored) > c.ttl {
+			delete(c.entries, k)
+		}
+	}
+	for len(c.entries) >= c.maxEntries {
+		oldest := ""
+		for k, entry := range c.entries {
+			if oldest == "" || entry.stored.Before(c.entries[oldest].stored) {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = cacheEntry{reply: reply, stored: now}
+}
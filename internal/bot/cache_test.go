@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"telecode/internal/config"
+)
+
+func TestCachedReplyScope(t *testing.T) {
+	scope := cacheScope{key: sessionKey{chatID: 1}, workDir: "/repo", sessionID: "s1", cli: "claude", model: "sonnet"}
+	other := func(change func(*cacheScope)) cacheScope {
+		s := scope
+		change(&s)
+		return s
+	}
+
+	tests := []struct {
+		name      string
+		workspace string
+		scope     cacheScope
+		want      bool
+	}{
+		{name: "same scope", workspace: "a", scope: scope, want: true},
+		{name: "other workspace", workspace: "b", scope: scope},
+		{name: "other chat", workspace: "a", scope: other(func(s *cacheScope) { s.key.chatID = 2 })},
+		{name: "other topic", workspace: "a", scope: other(func(s *cacheScope) { s.key.threadID = 7 })},
+		{name: "other directory", workspace: "a", scope: other(func(s *cacheScope) { s.workDir = "/repo/sub" })},
+		{name: "other session", workspace: "a", scope: other(func(s *cacheScope) { s.sessionID = "s2" })},
+		{name: "other model", workspace: "a", scope: other(func(s *cacheScope) { s.model = "opus" })},
+	}
+
+	cfg := config.WorkspaceConfig{Name: "a", CacheTTL: time.Minute, CacheMaxEntries: 10}
+	stored, _ := newTestWorkspace(t, cfg)
+	stored.Bot.CacheReply(scope, "what changed?", "answer")
+
+	// Workspaces sharing a cache must not see each other's replies either
+	otherCfg := cfg
+	otherCfg.Name = "b"
+	otherWs, _ := newTestWorkspace(t, otherCfg)
+	otherWs.Bot.cache = stored.Bot.cache
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := stored.Bot
+			if tt.workspace == "b" {
+				b = otherWs.Bot
+			}
+			reply, _, ok := b.CachedReply(tt.scope, "what changed?")
+			if ok != tt.want {
+				t.Errorf("cache hit = %v (%q), want %v", ok, reply, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+// Callback data of the inline keyboard buttons
+const (
+	callbackResetConfirm = "reset:yes"
+	callbackResetCancel  = "reset:cancel"
+	callbackCLIPrefix    = "cli:" // Followed by the CLI name
+)
+
+// resetKeyboard asks the user to confirm a session reset
+func resetKeyboard(ws *WorkspaceBot, key sessionKey) *telego.InlineKeyboardMarkup {
+	return tu.InlineKeyboard(tu.InlineKeyboardRow(
+		tu.InlineKeyboardButton(ws.Bot.t(key, "reset.yes")).WithCallbackData(callbackResetConfirm),
+		tu.InlineKeyboardButton(ws.Bot.t(key, "reset.cancel")).WithCallbackData(callbackResetCancel),
+	))
+}
+
+// cliKeyboard offers a button for each CLI, marking the current one
+func cliKeyboard(clis []string, current string) *telego.InlineKeyboardMarkup {
+	buttons := make([]telego.InlineKeyboardButton, 0, len(clis))
+	for _, cli := range clis {
+		label := cli
+		if cli == current {
+			label = "✅ " + cli
+		}
+		buttons = append(buttons, tu.InlineKeyboardButton(label).WithCallbackData(callbackCLIPrefix+cli))
+	}
+	return tu.InlineKeyboard(buttons)
+}
+
+// callbackEnabled reports whether the command behind a button is enabled. A
+// reset is confirmed for /new as well as /clear, so either one will do.
+func callbackEnabled(ws *WorkspaceBot, data string) bool {
+	switch {
+	case strings.HasPrefix(data, callbackCLIPrefix):
+		return ws.Bot.CommandEnabled("/cli")
+	case data == callbackResetConfirm:
+		return ws.Bot.CommandEnabled("/new") || ws.Bot.CommandEnabled("/clear")
+	}
+	return true
+}
+
+// handleCallbackQuery handles presses of inline keyboard buttons
+func (m *Manager) handleCallbackQuery(ctx context.Context, ws *WorkspaceBot, query *telego.CallbackQuery) error {
+	answer := &telego.AnswerCallbackQueryParams{CallbackQueryID: query.ID}
+	if query.Message == nil {
+		return ws.TgBot.AnswerCallbackQuery(ctx, answer)
+	}
+
+	chatID := query.Message.GetChat().ID
+	if msg, ok := query.Message.(*telego.Message); ok {
+		ctx = withThread(ctx, msg)
+	}
+	key := chatKey(ctx, chatID)
+	if !ws.Bot.IsAllowed(chatID) {
+		return nil
+	}
+	if !ws.Bot.IsAuthorized(query.From.ID) {
+		answer.Text = ws.Bot.t(key, "auth.denied")
+		return ws.TgBot.AnswerCallbackQuery(ctx, answer)
+	}
+
+	// Buttons outlive their message, the command may have been disabled since
+	if !callbackEnabled(ws, query.Data) {
+		answer.Text = ws.Bot.t(key, "command.disabled")
+		return ws.TgBot.AnswerCallbackQuery(ctx, answer)
+	}
+
+	var reply string
+	switch {
+	case strings.HasPrefix(query.Data, callbackCLIPrefix):
+		reply = changeCLI(ws, key, strings.TrimPrefix(query.Data, callbackCLIPrefix))
+	case query.Data == callbackResetConfirm:
+		ws.Bot.NewSession(key)
+		reply = newSessionText(ws, key)
+		answer.Text = ws.Bot.t(key, "reset.done")
+	case query.Data == callbackResetCancel:
+		reply = escapeMarkdownV2(ws.Bot.t(key, "reset.canceled"))
+	default:
+		return ws.TgBot.AnswerCallbackQuery(ctx, answer)
+	}
+
+	if err := ws.TgBot.AnswerCallbackQuery(ctx, answer); err != nil {
+		return err
+	}
+	// Replace the question, which also removes the keyboard
+	return ws.editWithRetry(ctx, tu.EditMessageText(tu.ID(chatID), query.Message.GetMessageID(), reply).WithParseMode(telego.ModeMarkdownV2))
+}
@@ -0,0 +1,109 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// collectBuffer holds the messages of a chat in collect mode that are
+// waiting to be sent as one prompt
+type collectBuffer struct {
+	ws       *WorkspaceBot
+	chatID   int64
+	messages []string
+	timer    *time.Timer
+	done     func()
+}
+
+// collectKey identifies the collect buffer of a conversation
+func collectKey(ws *WorkspaceBot, key sessionKey) string {
+	return fmt.Sprintf("%s/%s", ws.Config.Name, key)
+}
+
+// bufferPrompt adds a message to the chat's collect buffer. The messages are
+// sent as a single prompt once none arrived for the collect window.
+func (m *Manager) bufferPrompt(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) {
+	key := collectKey(ws, chatKey(ctx, chatID))
+
+	m.collectMu.Lock()
+	defer m.collectMu.Unlock()
+
+	buf := m.collects[key]
+	if buf == nil {
+		buf = &collectBuffer{ws: ws, chatID: chatID, done: m.trackHandler()}
+		buf.timer = time.AfterFunc(ws.Config.CollectWindow, func() {
+			m.flushCollected(ctx, key)
+		})
+		m.collects[key] = buf
+	} else {
+		buf.timer.Reset(ws.Config.CollectWindow)
+	}
+	buf.messages = append(buf.messages, text)
+}
+
+// flushCollectedNow sends the chat's buffered messages right away, without
+// waiting for the collect window to end
+func (m *Manager) flushCollectedNow(ctx context.Context, ws *WorkspaceBot, chatID int64) {
+	key := collectKey(ws, chatKey(ctx, chatID))
+
+	m.collectMu.Lock()
+	buf := m.collects[key]
+	// If the timer already fired, it flushes the buffer itself
+	if buf != nil && buf.timer.Stop() {
+		delete(m.collects, key)
+	} else {
+		buf = nil
+	}
+	m.collectMu.Unlock()
+
+	if buf != nil {
+		go m.sendCollected(ctx, buf)
+	}
+}
+
+// flushCollected sends the buffered messages of a chat once its collect
+// window ended
+func (m *Manager) flushCollected(ctx context.Context, key string) {
+	m.collectMu.Lock()
+	buf := m.collects[key]
+	delete(m.collects, key)
+	m.collectMu.Unlock()
+
+	if buf != nil {
+		m.sendCollected(ctx, buf)
+	}
+}
+
+// sendCollected sends the messages of a collect buffer as one prompt
+func (m *Manager) sendCollected(ctx context.Context, buf *collectBuffer) {
+	defer buf.done()
+
+	prompt := strings.Join(buf.messages, "\n\n")
+	if err := m.handleMessage(ctx, buf.ws, buf.chatID, prompt, nil); err != nil {
+		fmt.Printf("❌ Error handling collected messages for %s: %v\n", buf.ws.Config.Name, err)
+	}
+}
+
+// CollectEnabled reports whether the chat's messages are collected into one
+// prompt; the workspace's collect_mode applies until the chat sets its own
+func (b *Bot) CollectEnabled(key sessionKey) bool {
+	b.settingsMu.RLock()
+	defer b.settingsMu.RUnlock()
+	if collect := b.chatSettings[key].Collect; collect != nil {
+		return *collect
+	}
+	return b.collectMode
+}
+
+// SetCollect turns collect mode on or off for a chat
+func (b *Bot) SetCollect(key sessionKey, on bool) {
+	b.settingsMu.Lock()
+	settings := b.chatSettings[key]
+	settings.Collect = &on
+	b.chatSettings[key] = settings
+	b.settingsMu.Unlock()
+
+	b.persistSessions()
+}
@@ -0,0 +1,87 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+)
+
+// commandInfo describes a bot command
+type commandInfo struct {
+	Name        string
+	Description string
+}
+
+// commands lists all commands supported by the bot, in the order shown by /help
+var commands = []commandInfo{
+	{Name: "/new", Description: "Start a new session with the default CLI and model"},
+	{Name: "/clear", Description: "Same as /new"},
+	{Name: "/undo", Description: "Restore the previous session"},
+	{Name: "/sessions", Description: "List the sessions this chat used"},
+	{Name: "/resume", Description: "Switch to an earlier session (/resume <id>)"},
+	{Name: "/status", Description: "Show current status (workspace, CLI, session)"},
+	{Name: "/cli", Description: "Show or switch the CLI (claude | opencode | aider | gemini)"},
+	{Name: "/cd", Description: "Show or change the working directory (/cd <path>)"},
+	{Name: "/context", Description: "Show the file tree of the working directory (/context <path> for a subdirectory)"},
+	{Name: "/diff", Description: "Show uncommitted changes of the working directory (/diff --stat for a summary)"},
+	{Name: "/commit", Description: "Commit all changes of the working directory (/commit <message>, needs allow_git)"},
+	{Name: "/files", Description: "List recently changed files or download one (/files <path>)"},
+	{Name: "/model", Description: "Show or switch the model (/model default restores it)"},
+	{Name: "/persona", Description: "Show the workspace's system prompt"},
+	{Name: "/stats", Description: "Show chat and CLI statistics (/stats reset clears the chat's)"},
+	{Name: "/collect", Description: "Combine quickly sent messages into one prompt (/collect on|off)"},
+	{Name: "/lang", Description: "Show or set the language of bot messages (/lang en|ko)"},
+	{Name: "/verbose", Description: "Show the command, duration and exit code after answers (/verbose on|off)"},
+	{Name: "/dryrun", Description: "Show the command prompts would run without running it (/dryrun on|off)"},
+	{Name: "/retry", Description: "Send the last prompt again"},
+	{Name: "/nocache", Description: "Run a prompt without using a cached answer (/nocache <prompt>)"},
+	{Name: "/cancel", Description: "Cancel the currently running command and background tasks"},
+	{Name: "/stop", Description: "Cancel all commands and clear the session"},
+	{Name: "/bg", Description: "Run a prompt in the background and get the answer when done (/bg <prompt>)"},
+	{Name: "/tasks", Description: "List this chat's background tasks"},
+	{Name: "/compare", Description: "Run a prompt with claude and opencode and show both answers (/compare <prompt>, needs allow_compare)"},
+	{Name: "/resend", Description: "Send the last response again"},
+	{Name: "/history", Description: "Show recent prompts and responses (/history [count])"},
+	{Name: "/workspaces", Description: "List the workspaces served by this bot"},
+	{Name: "/workspace", Description: "Switch this chat to another workspace (/workspace <name>)"},
+	{Name: "/env", Description: "Show the effective workspace configuration (admins only)"},
+	{Name: "/ping", Description: "Check that the bot is responsive"},
+	{Name: "/whoami", Description: "Show your user ID and this chat's ID"},
+	{Name: "/help", Description: "Show this help message"},
+}
+
+// optInCommands change the working directory's git history or cost more than
+// a prompt, so they are disabled unless a workspace enables them
+var optInCommands = []string{"/commit", "/compare"}
+
+// isKnownCommand reports whether name is one of the bot's commands
+func isKnownCommand(name string) bool {
+	if name == "/start" {
+		return true
+	}
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// helpText builds the /help message in lang from the command list, leaving
+// out commands for which enabled returns false. Descriptions are translated
+// as the message "help" followed by the command, e.g. "help/new".
+func helpText(lang string, enabled func(cmd string) bool) string {
+	var sb strings.Builder
+	sb.WriteString("📖 *" + escapeMarkdownV2(translate(lang, "help.title")) + "*\n\n")
+	for _, cmd := range commands {
+		if !enabled(cmd.Name) {
+			continue
+		}
+		description := cmd.Description
+		if translated, ok := messages[lang]["help"+cmd.Name]; ok {
+			description = translated
+		}
+		sb.WriteString(fmt.Sprintf("%s \\- %s\n", escapeMarkdownV2(cmd.Name), escapeMarkdownV2(description)))
+	}
+	sb.WriteString("\n" + escapeMarkdownV2(translate(lang, "help.footer")))
+	return sb.String()
+}
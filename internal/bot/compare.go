@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// compareCLIs are the CLIs /compare runs a prompt with, in answer order
+var compareCLIs = []string{"claude", "opencode"}
+
+// comparison is the answer of one CLI to a /compare prompt
+type comparison struct {
+	cli   string
+	reply string
+}
+
+// handleCompare handles the /compare command, running a prompt with each of
+// compareCLIs at once, without sessions, and sending their answers one after
+// another. The runs share the command timeout.
+func (m *Manager) handleCompare(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	prompt := strings.TrimSpace(strings.TrimPrefix(text, getCommandFromMessage(text)))
+	if prompt == "" {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "compare.usage"))
+	}
+	if len(prompt) > ws.Config.MaxPromptBytes {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.too_long", len(prompt), ws.Config.MaxPromptBytes))
+	}
+	for _, cli := range compareCLIs {
+		if ws.Bot.GetExecutor(cli) == nil {
+			return sendText(ctx, ws, chatID, ws.Bot.t(key, "compare.not_allowed", cli))
+		}
+	}
+
+	// Wait for the previous command in this chat to finish
+	release, err := ws.Bot.AcquireRun(ctx, key, func() {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.queued"))
+	})
+	if errors.Is(err, ErrQueueFull) {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.queue_full"))
+	}
+	if errors.Is(err, ErrDropped) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := sendText(ctx, ws, chatID, ws.Bot.t(key, "compare.started", strings.Join(compareCLIs, ws.Bot.t(key, "compare.and")))); err != nil {
+		return err
+	}
+
+	typingCtx, cancelTyping := context.WithCancel(ctx)
+	defer cancelTyping()
+	if !ws.Config.DisableTypingAction {
+		go sendTypingAction(typingCtx, ws, chatID)
+	}
+
+	// Cancelable via /cancel, like a prompt
+	runCtx, finishRun := ws.Bot.StartRun(ctx, key)
+	defer finishRun()
+	cmdCtx, cancelCmd := context.WithTimeout(runCtx, ws.Config.CommandTimeout)
+	defer cancelCmd()
+
+	answers := make([]comparison, len(compareCLIs))
+	var wg sync.WaitGroup
+	for i, cli := range compareCLIs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply, _ := m.runOneOff(ctx, cmdCtx, ws, chatID, cli, ws.Bot.OneOffModel(cli), prompt)
+			answers[i] = comparison{cli: cli, reply: reply}
+		}()
+	}
+	wg.Wait()
+	cancelTyping()
+
+	for _, answer := range answers {
+		if err := sendChunks(ctx, ws, chatID, fmt.Sprintf("🤖 %s\n\n%s", answer.cli, answer.reply)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runOneOff runs prompt with cli in a one-off command bounded by cmdCtx and
+// returns the reply, and whether the command succeeded. It is used by
+// /compare and /bg.
+func (m *Manager) runOneOff(ctx, cmdCtx context.Context, ws *WorkspaceBot, chatID int64, cli, model, prompt string) (string, bool) {
+	_, cmd, stdin := ws.Bot.BuildOneOffCommand(cli, model, prompt, nil)
+	if cmd == nil {
+		return ws.Bot.t(chatKey(ctx, chatID), "prompt.no_command"), false
+	}
+
+	releaseSlot, err := m.acquireSlot(cmdCtx, ws, nil)
+	if err != nil {
+		return formatCommandResult(CommandResult{ExitCode: -1, Err: err}, "", ws.Config.CommandTimeout), false
+	}
+	defer releaseSlot()
+
+	promptsTotal.WithLabelValues(ws.Config.Name, cli).Inc()
+	activeCommands.WithLabelValues(ws.Config.Name).Inc()
+	workDir := ws.Bot.GetWorkDir(chatKey(ctx, chatID))
+	_, finishDirRun := ws.Bot.TrackRun(workDir)
+	started := time.Now()
+	result := runCommandWithDir(cmdCtx, cmd, workDir, ws.Config.Env, stdin, nil)
+	duration := time.Since(started)
+	finishDirRun()
+	activeCommands.WithLabelValues(ws.Config.Name).Dec()
+	m.logCommand(ws, chatID, cli, model, prompt, result, duration)
+	observeCommand(ws.Config.Name, cli, result, duration)
+
+	output := applyPostProcess(ctx, ws, cleanOutput(ws, formatOutput(ws.Bot.GetExecutor(cli), result.Stdout)))
+	result.Stderr = cleanOutput(ws, result.Stderr)
+	return formatCommandResult(result, output, ws.Config.CommandTimeout), result.Err == nil && result.ExitCode == 0
+}
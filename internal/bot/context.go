@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// contextDepth is how many directory levels /context shows
+	contextDepth = 3
+	// maxContextEntries keeps the /context tree within a few messages
+	maxContextEntries = 200
+)
+
+// treeNode is a directory of the /context tree
+type treeNode struct {
+	dirs  map[string]*treeNode
+	files []string
+	total int // Files in the directory and all its subdirectories
+}
+
+// add adds the file at the slash-separated path below n
+func (n *treeNode) add(path string) {
+	n.total++
+	dir, rest, found := strings.Cut(path, "/")
+	if !found {
+		n.files = append(n.files, dir)
+		return
+	}
+	if n.dirs == nil {
+		n.dirs = make(map[string]*treeNode)
+	}
+	child := n.dirs[dir]
+	if child == nil {
+		child = &treeNode{}
+		n.dirs[dir] = child
+	}
+	child.add(rest)
+}
+
+// render writes the tree below n, directories first, indented by depth.
+// Directories deeper than contextDepth are summarized with their file count.
+// remaining is decremented per entry and rendering stops once it is used up.
+func (n *treeNode) render(sb *strings.Builder, depth int, remaining *int) {
+	names := make([]string, 0, len(n.dirs))
+	for name := range n.dirs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	sort.Strings(n.files)
+
+	indent := strings.Repeat("  ", depth)
+	for _, name := range names {
+		if *remaining <= 0 {
+			return
+		}
+		*remaining--
+		child := n.dirs[name]
+		if depth+1 >= contextDepth {
+			sb.WriteString(fmt.Sprintf("%s%s/ (%d files)\n", indent, name, child.total))
+			continue
+		}
+		sb.WriteString(indent + name + "/\n")
+		child.render(sb, depth+1, remaining)
+	}
+	for _, name := range n.files {
+		if *remaining <= 0 {
+			return
+		}
+		*remaining--
+		sb.WriteString(indent + name + "\n")
+	}
+}
+
+// listFiles returns the files below dir as slash-separated relative paths.
+// In a git repository, files ignored by .gitignore are left out; elsewhere
+// hidden directories and node_modules are skipped.
+func listFiles(ctx context.Context, dir string) ([]string, error) {
+	if isGitRepo(ctx, dir) {
+		out, err := runGit(ctx, dir, "ls-files", "--cached", "--others", "--exclude-standard", "-z")
+		if err != nil {
+			return nil, err
+		}
+		return strings.FieldsFunc(out, func(r rune) bool { return r == 0 }), nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if entry.IsDir() {
+			if path != dir && (strings.HasPrefix(entry.Name(), ".") || entry.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(files) >= maxScannedFiles {
+			return errTooManyFiles
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errTooManyFiles) {
+		return nil, err
+	}
+	return files, nil
+}
+
+// handleContext handles the /context command, showing the file tree of the
+// working directory, or of one of its subdirectories with /context <path>,
+// down to contextDepth levels
+func (m *Manager) handleContext(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	workDir := ws.Bot.GetWorkDir(key)
+	dir := workDir
+	if sub := strings.TrimSpace(strings.TrimPrefix(text, getCommandFromMessage(text))); sub != "" {
+		dir = sub
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+	}
+
+	// Resolve symlinks so a link can't point outside the working directory
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "context.not_found"))
+	}
+	root, err := filepath.EvalSymlinks(workDir)
+	if err != nil || !isWithin(root, resolved) {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "context.outside"))
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "context.not_dir"))
+	}
+
+	files, err := listFiles(ctx, resolved)
+	if err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "files.list_failed", err))
+	}
+	if len(files) == 0 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "context.empty", resolved))
+	}
+
+	tree := &treeNode{}
+	for _, file := range files {
+		tree.add(file)
+	}
+	var sb strings.Builder
+	remaining := maxContextEntries
+	tree.render(&sb, 0, &remaining)
+	if remaining <= 0 {
+		sb.WriteString("…\n")
+	}
+
+	header := ws.Bot.t(key, "context.header", resolved, tree.total)
+	return sendChunks(ctx, ws, chatID, header+"\n"+codeFence+"\n"+sb.String()+codeFence)
+}
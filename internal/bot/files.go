@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// recentFilesWindow is how far back /files looks for changed files
+	recentFilesWindow = time.Hour
+	// maxListedFiles caps the changed files listed in one message
+	maxListedFiles = 20
+	// maxScannedFiles stops scanning very large working directories
+	maxScannedFiles = 20000
+)
+
+// errTooManyFiles stops the scan of a working directory with too many files
+var errTooManyFiles = errors.New("too many files")
+
+// changedFile is a file modified in the working directory
+type changedFile struct {
+	Path    string // Relative to the working directory
+	ModTime time.Time
+	Size    int64
+}
+
+// changedFiles returns the files in root modified since the given time,
+// newest first. Hidden directories such as .git and node_modules are skipped.
+func changedFiles(root string, since time.Time) ([]changedFile, error) {
+	var files []changedFile
+	scanned := 0
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if entry.IsDir() {
+			if path != root && (strings.HasPrefix(entry.Name(), ".") || entry.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if scanned++; scanned > maxScannedFiles {
+			return errTooManyFiles
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().Before(since) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, changedFile{Path: rel, ModTime: info.ModTime(), Size: info.Size()})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errTooManyFiles) {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime.After(files[j].ModTime) })
+	return files, nil
+}
+
+// formatChangedFiles lists changed files as MarkdownV2 under the title
+// message titleID, in the conversation's language
+func formatChangedFiles(ws *WorkspaceBot, key sessionKey, titleID string, files []changedFile) string {
+	var sb strings.Builder
+	sb.WriteString(escapeMarkdownV2(ws.Bot.t(key, titleID)) + "\n\n")
+	for i, file := range files {
+		if i >= maxListedFiles {
+			sb.WriteString(escapeMarkdownV2(ws.Bot.t(key, "files.more", len(files)-maxListedFiles)) + "\n")
+			break
+		}
+		sb.WriteString(fmt.Sprintf("• %s %s\n", mdCode(file.Path), escapeMarkdownV2(ws.Bot.t(key, "files.size", file.Size))))
+	}
+	sb.WriteString("\n" + escapeMarkdownV2(ws.Bot.t(key, "files.hint")))
+	return sb.String()
+}
+
+// sendChangedFiles tells the chat which files a run that started at started
+// created or modified
+func sendChangedFiles(ctx context.Context, ws *WorkspaceBot, chatID int64, workDir string, started time.Time) error {
+	files, err := changedFiles(workDir, started)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	return sendFormatted(ctx, ws, chatID, formatChangedFiles(ws, chatKey(ctx, chatID), "files.changed", files))
+}
+
+// handleFiles handles the /files command: without arguments it lists the
+// recently changed files of the working directory, with a path it sends that
+// file as a document
+func (m *Manager) handleFiles(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	workDir := ws.Bot.GetWorkDir(key)
+	path := strings.TrimSpace(strings.TrimPrefix(text, getCommandFromMessage(text)))
+	if path == "" {
+		files, err := changedFiles(workDir, time.Now().Add(-recentFilesWindow))
+		if err != nil {
+			return sendText(ctx, ws, chatID, ws.Bot.t(key, "files.list_failed", err))
+		}
+		if len(files) == 0 {
+			return sendText(ctx, ws, chatID, ws.Bot.t(key, "files.none", recentFilesWindow))
+		}
+		return sendFormatted(ctx, ws, chatID, formatChangedFiles(ws, key, "files.recent", files))
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workDir, path)
+	}
+	// Resolve symlinks so a link can't point outside the working directory
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "files.not_found"))
+	}
+	root, err := filepath.EvalSymlinks(workDir)
+	if err != nil || !isWithin(root, resolved) {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "files.outside"))
+	}
+	info, err := os.Stat(resolved)
+	if err != nil || !info.Mode().IsRegular() {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "files.not_regular"))
+	}
+	if info.Size() > ws.Config.MaxAttachBytes {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "files.too_large", info.Size(), ws.Config.MaxAttachBytes))
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "files.read_failed", err))
+	}
+	return ws.sendDocumentWithRetry(ctx, chatID, filepath.Base(resolved), data, "")
+}
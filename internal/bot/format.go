@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	ta "github.com/mymmrac/telego/telegoapi"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+// telegramMaxLength is Telegram's limit for message text, in UTF-16 code units
+const telegramMaxLength = 4096
+
+// formattedMessage creates a MarkdownV2 message. Dynamic values in text must
+// be escaped with escapeMarkdownV2 or mdCode.
+func formattedMessage(chatID int64, text string) *telego.SendMessageParams {
+	return tu.Message(tu.ID(chatID), text).WithParseMode(telego.ModeMarkdownV2)
+}
+
+// sendFormatted sends a MarkdownV2 message
+func sendFormatted(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	_, err := ws.sendWithRetry(ctx, formattedMessage(chatID, text))
+	return err
+}
+
+// sendText sends plain text, escaped for MarkdownV2
+func sendText(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	return sendFormatted(ctx, ws, chatID, escapeMarkdownV2(text))
+}
+
+// mdCode renders text as a MarkdownV2 code span
+func mdCode(text string) string {
+	return "`" + escapeMarkdownV2Code(text) + "`"
+}
+
+// formatOutputChunk renders a chunk of CLI output as MarkdownV2, keeping its
+// code blocks. ok is false if escaping made it too long for one message.
+func formatOutputChunk(chunk string) (string, bool) {
+	formatted := formatMarkdownV2(chunk)
+	return formatted, utf16Len([]rune(formatted)) <= telegramMaxLength
+}
+
+// isParseError reports whether Telegram rejected a message's formatting
+func isParseError(err error) bool {
+	var apiErr *ta.Error
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == http.StatusBadRequest &&
+		strings.Contains(apiErr.Description, "can't parse entities")
+}
+
+// isTooLongError reports whether Telegram rejected a message as too long,
+// which chunking can miss, e.g. when entities expand the text
+func isTooLongError(err error) bool {
+	var apiErr *ta.Error
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == http.StatusBadRequest &&
+		(strings.Contains(apiErr.Description, "message is too long") || strings.Contains(apiErr.Description, "MESSAGE_TOO_LONG"))
+}
+
+// sendOutputChunk sends a chunk of CLI output as MarkdownV2, falling back to
+// plain text if it can't be formatted
+func sendOutputChunk(ctx context.Context, ws *WorkspaceBot, chatID int64, chunk string) (*telego.Message, error) {
+	if formatted, ok := formatOutputChunk(chunk); ok {
+		msg, err := ws.sendWithRetry(ctx, formattedMessage(chatID, formatted))
+		if !isParseError(err) {
+			return msg, err
+		}
+	}
+	return ws.sendWithRetry(ctx, tu.Message(tu.ID(chatID), chunk))
+}
+
+// editOutputChunk replaces a message with a chunk of CLI output, like
+// sendOutputChunk
+func editOutputChunk(ctx context.Context, ws *WorkspaceBot, chatID int64, messageID int, chunk string) error {
+	if formatted, ok := formatOutputChunk(chunk); ok {
+		err := ws.editWithRetry(ctx, tu.EditMessageText(tu.ID(chatID), messageID, formatted).WithParseMode(telego.ModeMarkdownV2))
+		if !isParseError(err) {
+			return err
+		}
+	}
+	return ws.editWithRetry(ctx, tu.EditMessageText(tu.ID(chatID), messageID, chunk))
+}
@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// gitTimeout limits the git commands run by /diff and /commit
+	gitTimeout = 30 * time.Second
+	// maxInlineDiffLength is the longest diff sent as messages; longer ones
+	// are sent as diff.patch
+	maxInlineDiffLength = 3 * maxMessageLength
+)
+
+// runGit runs git with args in dir and returns its stdout. Failures are
+// returned with git's error message, which some commands print to stdout.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	result := runCommandWithDir(ctx, append([]string{"git"}, args...), dir, nil, "", nil)
+	if result.Err != nil {
+		return "", result.Err
+	}
+	if result.ExitCode != 0 {
+		message := strings.TrimSpace(result.Stderr)
+		if message == "" {
+			message = strings.TrimSpace(result.Stdout)
+		}
+		return "", fmt.Errorf("%s (exit code %d)", message, result.ExitCode)
+	}
+	return result.Stdout, nil
+}
+
+// isGitRepo reports whether dir is inside a git work tree
+func isGitRepo(ctx context.Context, dir string) bool {
+	out, err := runGit(ctx, dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(out) == "true"
+}
+
+// handleDiff handles the /diff command, showing the uncommitted changes of
+// the working directory. /diff --stat shows a summary instead.
+func (m *Manager) handleDiff(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	args := strings.Fields(text)
+	stat := len(args) > 1 && args[1] == "--stat"
+	if len(args) > 1 && !stat {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "diff.usage"))
+	}
+
+	dir := ws.Bot.GetWorkDir(key)
+	if !isGitRepo(ctx, dir) {
+		return sendFormatted(ctx, ws, chatID, ws.Bot.tMarkdown(key, "git.not_repo", mdCode(dir)))
+	}
+
+	gitArgs := []string{"diff", "--no-color"}
+	if stat {
+		gitArgs = append(gitArgs, "--stat")
+	}
+	// Staged changes count too; a repository without commits has no HEAD
+	// to compare with
+	diff, err := runGit(ctx, dir, append(gitArgs, "HEAD")...)
+	if err != nil {
+		diff, err = runGit(ctx, dir, gitArgs...)
+	}
+	if err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "git.failed", "diff", err))
+	}
+
+	diff = strings.TrimRight(diff, "\n")
+	if diff == "" {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "diff.none"))
+	}
+	if len(diff) > maxInlineDiffLength {
+		return ws.sendDocumentWithRetry(ctx, chatID, "diff.patch", []byte(diff+"\n"), ws.Bot.t(key, "diff.as_file", len(diff)))
+	}
+
+	lang := "diff"
+	if stat {
+		lang = ""
+	}
+	return sendChunks(ctx, ws, chatID, codeFence+lang+"\n"+diff+"\n"+codeFence)
+}
+
+// handleCommit handles the /commit command, committing all changes of the
+// working directory. It is disabled unless allow_git is set.
+func (m *Manager) handleCommit(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	message := strings.TrimSpace(strings.TrimPrefix(text, getCommandFromMessage(text)))
+	if message == "" {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "commit.usage"))
+	}
+
+	dir := ws.Bot.GetWorkDir(key)
+	if !isGitRepo(ctx, dir) {
+		return sendFormatted(ctx, ws, chatID, ws.Bot.tMarkdown(key, "git.not_repo", mdCode(dir)))
+	}
+
+	status, err := runGit(ctx, dir, "status", "--porcelain")
+	if err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "git.failed", "status", err))
+	}
+	if strings.TrimSpace(status) == "" {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "commit.nothing"))
+	}
+
+	if _, err := runGit(ctx, dir, "add", "-A"); err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "git.failed", "add", err))
+	}
+	// The "=" form keeps a message starting with "-" from being read as a flag
+	if _, err := runGit(ctx, dir, "commit", "--message="+message); err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "git.failed", "commit", err))
+	}
+	hash, err := runGit(ctx, dir, "rev-parse", "--short", "HEAD")
+	if err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "commit.no_hash", err))
+	}
+	return sendFormatted(ctx, ws, chatID, ws.Bot.tMarkdown(key, "commit.done", mdCode(strings.TrimSpace(hash))))
+}
@@ -2,176 +2,791 @@ package bot
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf16"
 
 	"github.com/mymmrac/telego"
 	tu "github.com/mymmrac/telego/telegoutil"
+
+	"telecode/internal/executor"
 )
 
-// handleNewSession handles the /new command
+// handleNewSession handles the /new and /clear commands
 func (m *Manager) handleNewSession(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
-	ws.Bot.NewSession(chatID)
-	_, err := ws.TgBot.SendMessage(ctx, tu.Message(
-		tu.ID(chatID),
-		"✅ **New session started!**\n\nYou can now send your message.",
-	).WithParseMode(telego.ModeMarkdown))
-	return err
+	key := chatKey(ctx, chatID)
+	// Ask before throwing away an existing session
+	if ws.Config.ConfirmReset && ws.Bot.GetSessionID(key) != "" {
+		_, err := ws.sendWithRetry(ctx, formattedMessage(
+			chatID,
+			escapeMarkdownV2(ws.Bot.t(key, "reset.confirm")),
+		).WithReplyMarkup(resetKeyboard(ws, key)))
+		return err
+	}
+
+	ws.Bot.NewSession(key)
+	return sendFormatted(ctx, ws, chatID, newSessionText(ws, key))
+}
+
+// newSessionText is the MarkdownV2 reply to a session reset
+func newSessionText(ws *WorkspaceBot, key sessionKey) string {
+	return "*" + escapeMarkdownV2(ws.Bot.t(key, "session.new")) + "*\n\n" + escapeMarkdownV2(ws.Bot.t(key, "session.new_hint"))
+}
+
+// handleUndo handles the /undo command
+func (m *Manager) handleUndo(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	if sessionID, ok := ws.Bot.UndoSession(key); ok {
+		return sendFormatted(ctx, ws, chatID, escapeMarkdownV2(ws.Bot.t(key, "session.restored"))+" "+mdCode(sessionID))
+	}
+	return sendText(ctx, ws, chatID, ws.Bot.t(key, "session.no_undo"))
+}
+
+// handleSessions handles the /sessions command
+func (m *Manager) handleSessions(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	records := ws.Bot.SessionHistory(key)
+	if len(records) == 0 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "session.none"))
+	}
+
+	current := ws.Bot.GetSessionID(key)
+	var sb strings.Builder
+	sb.WriteString("🗂 *" + escapeMarkdownV2(ws.Bot.t(key, "sessions.title")) + "*\n\n")
+	for _, record := range records {
+		marker := "▫️"
+		if record.ID == current {
+			marker = "▶️"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s \\- %s\n", marker, mdCode(record.ID), escapeMarkdownV2(record.LastUsed.Format("2006-01-02 15:04"))))
+	}
+	sb.WriteString("\n" + escapeMarkdownV2(ws.Bot.t(key, "sessions.hint")))
+	return sendFormatted(ctx, ws, chatID, sb.String())
+}
+
+// handleResume handles the /resume command
+func (m *Manager) handleResume(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "resume.usage"))
+	}
+
+	sessionID, err := ws.Bot.ResumeSession(key, args[1])
+	if err != nil {
+		return sendText(ctx, ws, chatID, fmt.Sprintf("❌ %v", err))
+	}
+	return sendFormatted(ctx, ws, chatID, escapeMarkdownV2(ws.Bot.t(key, "resume.done"))+" "+mdCode(sessionID))
+}
+
+// handleRetry handles the /retry command, sending the last prompt again
+func (m *Manager) handleRetry(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	last, ok := ws.Bot.GetLastPrompt(key)
+	if !ok {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "retry.none"))
+	}
+
+	// Temp files of the last run are gone, download attachments again
+	files := make([]attachment, 0, len(last.Files))
+	defer func() {
+		for _, file := range files {
+			os.Remove(file.Path) // Clean up temp files
+		}
+	}()
+	for _, file := range last.Files {
+		file, err := redownload(ctx, ws, file)
+		if err != nil {
+			_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "retry.attachment_gone"))
+			return err
+		}
+		files = append(files, file)
+	}
+
+	return m.handlePrompt(ctx, ws, chatID, last.Prompt, files, false)
+}
+
+// handleNoCache handles the /nocache command, which runs a prompt without
+// answering it from the cache
+func (m *Manager) handleNoCache(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	prompt := strings.TrimSpace(strings.TrimPrefix(text, getCommandFromMessage(text)))
+	if prompt == "" {
+		return sendText(ctx, ws, chatID, ws.Bot.t(chatKey(ctx, chatID), "nocache.usage"))
+	}
+	return m.handlePrompt(ctx, ws, chatID, prompt, nil, false)
+}
+
+// handlePersona handles the /persona command
+func (m *Manager) handlePersona(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	reply := ws.Bot.t(key, "persona.none")
+	if systemPrompt := ws.Bot.SystemPrompt(); systemPrompt != "" {
+		reply = ws.Bot.t(key, "persona.current") + "\n\n" + systemPrompt
+	}
+	return sendChunks(ctx, ws, chatID, reply)
+}
+
+// handleWhoami handles the /whoami command
+func (m *Manager) handleWhoami(ctx context.Context, ws *WorkspaceBot, chatID, userID int64) error {
+	key := chatKey(ctx, chatID)
+	authorized := ws.Bot.t(key, "whoami.no")
+	if ws.Bot.IsAllowed(chatID) && ws.Bot.IsAuthorized(userID) {
+		authorized = ws.Bot.t(key, "whoami.yes")
+	}
+
+	return sendFormatted(ctx, ws, chatID, "🪪 *"+escapeMarkdownV2(ws.Bot.t(key, "whoami.title"))+"*\n"+
+		ws.Bot.tMarkdown(key, "whoami.body", mdCode(strconv.FormatInt(userID, 10)), mdCode(strconv.FormatInt(chatID, 10)), escapeMarkdownV2(authorized)))
+}
+
+// handlePing handles the /ping command
+func (m *Manager) handlePing(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	uptime := time.Since(m.startedAt).Round(time.Second)
+	return sendText(ctx, ws, chatID, ws.Bot.t(chatKey(ctx, chatID), "ping", uptime))
+}
+
+// redacted replaces secrets in /env output
+const redacted = "***"
+
+// handleEnv handles the /env command, showing the effective workspace
+// configuration to admins with secrets redacted
+func (m *Manager) handleEnv(ctx context.Context, ws *WorkspaceBot, chatID, userID int64) error {
+	key := chatKey(ctx, chatID)
+	if !ws.Bot.IsAdmin(userID) {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "env.admins_only"))
+	}
+
+	cfg := ws.Config
+	model := cfg.DefaultModel
+	if model == "" {
+		model = "(CLI default)"
+	}
+	envKeys := make([]string, 0, len(cfg.Env))
+	for key := range cfg.Env {
+		envKeys = append(envKeys, key+"="+redacted)
+	}
+	sort.Strings(envKeys)
+
+	lines := []string{
+		"name: " + cfg.Name,
+		"working_dir: " + cfg.WorkingDir,
+		"allowed_root: " + cfg.AllowedRoot,
+		"bot_token: " + redacted,
+		"default_cli: " + cfg.DefaultCLI,
+		"allowed_clis: " + strings.Join(cfg.AllowedCLIs, ", "),
+		"default_model: " + model,
+		"command_timeout: " + cfg.CommandTimeout.String(),
+		fmt.Sprintf("max_concurrent: %d", cfg.MaxConcurrent),
+		fmt.Sprintf("queue_size: %d", cfg.QueueSize),
+		fmt.Sprintf("rate_limit: %d per %s", cfg.RateLimit, cfg.RateLimitWindow),
+		fmt.Sprintf("allowed_chats: %d, allowed_users: %d, admin_users: %d", len(cfg.AllowedChats), len(cfg.AllowedUsers), len(cfg.AdminUsers)),
+		"env: " + strings.Join(envKeys, ", "),
+	}
+	if cfg.SessionFile != "" {
+		lines = append(lines, "session_file: "+cfg.SessionFile)
+	}
+
+	return sendFormatted(ctx, ws, chatID, "⚙️ *"+escapeMarkdownV2(ws.Bot.t(key, "env.title"))+"*\n```\n"+escapeMarkdownV2Code(strings.Join(lines, "\n"))+"\n```")
 }
 
 // handleStatus handles the /status command
 func (m *Manager) handleStatus(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
-	cli, sessionID := ws.Bot.GetStatus(chatID)
-
-	statusMsg := fmt.Sprintf("📊 **Current Status**\n"+
-		"- Workspace: `%s`\n"+
-		"- Working Dir: `%s`\n"+
-		"- CLI: `%s`\n"+
-		"- Session: `%s`",
-		ws.Config.Name, ws.Config.WorkingDir, cli, sessionID)
-
-	_, err := ws.TgBot.SendMessage(ctx, tu.Message(
-		tu.ID(chatID),
-		statusMsg,
-	).WithParseMode(telego.ModeMarkdown))
-	return err
+	key := chatKey(ctx, chatID)
+	cli, sessionID := ws.Bot.GetStatus(key)
+	if sessionID == "" {
+		sessionID = "none" // Telegram rejects empty code spans
+	}
+
+	statusMsg := "📊 *" + escapeMarkdownV2(ws.Bot.t(key, "status.title")) + "*\n" +
+		ws.Bot.tMarkdown(key, "status.body",
+			mdCode(ws.Config.Name), mdCode(ws.Bot.GetWorkDir(key)), mdCode(cli), mdCode(sessionID),
+			escapeMarkdownV2(ws.Bot.RunState(key)))
+
+	return sendFormatted(ctx, ws, chatID, statusMsg)
 }
 
 // handleCLI handles the /cli command
 func (m *Manager) handleCLI(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
 	args := strings.Fields(text)
 
 	if len(args) == 1 {
-		// Get current CLI
-		cli := ws.Bot.GetCLI(chatID)
-		_, err := ws.TgBot.SendMessage(ctx, tu.Message(
-			tu.ID(chatID),
-			fmt.Sprintf("📋 Current CLI: `%s`", cli),
-		).WithParseMode(telego.ModeMarkdown))
+		// Show current CLI with buttons to switch
+		cli := ws.Bot.GetCLI(key)
+		_, err := ws.sendWithRetry(ctx, formattedMessage(
+			chatID,
+			ws.Bot.tMarkdown(key, "cli.current", mdCode(cli)),
+		).WithReplyMarkup(cliKeyboard(ws.Bot.SupportedCLIs(), cli)))
 		return err
 	}
 
-	// Change CLI
-	newCLI := args[1]
-	if newCLI != "claude" && newCLI != "opencode" {
-		_, err := ws.TgBot.SendMessage(ctx, tu.Message(
-			tu.ID(chatID),
-			"❌ Unsupported CLI. Use: claude | opencode",
-		))
-		return err
+	return sendFormatted(ctx, ws, chatID, changeCLI(ws, key, args[1]))
+}
+
+// changeCLI switches the conversation to a CLI and returns the MarkdownV2 reply
+func changeCLI(ws *WorkspaceBot, key sessionKey, newCLI string) string {
+	if ws.Bot.GetExecutor(newCLI) == nil {
+		return escapeMarkdownV2(ws.Bot.t(key, "cli.unsupported", strings.Join(ws.Bot.SupportedCLIs(), " | ")))
 	}
 
-	if err := ws.Bot.SetCLI(chatID, newCLI); err != nil {
-		_, err := ws.TgBot.SendMessage(ctx, tu.Message(
-			tu.ID(chatID),
-			fmt.Sprintf("❌ %v", err),
-		))
-		return err
+	if err := ws.Bot.SetCLI(key, newCLI); err != nil {
+		return escapeMarkdownV2(fmt.Sprintf("❌ %v", err))
 	}
 
-	_, err := ws.TgBot.SendMessage(ctx, tu.Message(
-		tu.ID(chatID),
-		fmt.Sprintf("✅ CLI changed to: `%s` (session reset)", newCLI),
-	).WithParseMode(telego.ModeMarkdown))
-	return err
+	return ws.Bot.tMarkdown(key, "cli.changed", mdCode(newCLI))
+}
+
+// handleCd handles the /cd command
+func (m *Manager) handleCd(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	path := strings.TrimSpace(strings.TrimPrefix(text, getCommandFromMessage(text)))
+	if path == "" {
+		return sendFormatted(ctx, ws, chatID, ws.Bot.tMarkdown(key, "cd.current", mdCode(ws.Bot.GetWorkDir(key))))
+	}
+
+	dir, err := ws.Bot.SetWorkDir(key, path)
+	if err != nil {
+		return sendText(ctx, ws, chatID, fmt.Sprintf("❌ %v", err))
+	}
+	return sendFormatted(ctx, ws, chatID, ws.Bot.tMarkdown(key, "cd.changed", mdCode(dir)))
+}
+
+// handleModel handles the /model command
+func (m *Manager) handleModel(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	args := strings.Fields(text)
+
+	if len(args) == 1 {
+		// Get current model
+		model := ws.Bot.GetModel(key)
+		if model == "" {
+			model = ws.Bot.t(key, "model.cli_default")
+		}
+		msg := ws.Bot.tMarkdown(key, "model.current", mdCode(model))
+		if allowed := ws.Bot.AllowedModels(); len(allowed) > 0 {
+			codes := make([]string, len(allowed))
+			for i, name := range allowed {
+				codes[i] = mdCode(name)
+			}
+			msg += "\n" + ws.Bot.tMarkdown(key, "model.available", strings.Join(codes, ", "))
+		}
+		return sendFormatted(ctx, ws, chatID, msg)
+	}
+
+	// Change model ("default" restores the workspace default)
+	newModel := args[1]
+	if newModel == "default" {
+		newModel = ""
+	}
+
+	if err := ws.Bot.SetModel(key, newModel); err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "model.not_allowed", err, strings.Join(ws.Bot.AllowedModels(), ", ")))
+	}
+
+	if newModel == "" {
+		newModel = "default"
+	}
+	return sendFormatted(ctx, ws, chatID, ws.Bot.tMarkdown(key, "model.changed", mdCode(newModel)))
+}
+
+// handleCollect handles the /collect command
+func (m *Manager) handleCollect(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	args := strings.Fields(text)
+	if len(args) == 1 {
+		state := "off"
+		if ws.Bot.CollectEnabled(key) {
+			state = "on"
+		}
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "collect.state", state))
+	}
+
+	switch args[1] {
+	case "on":
+		ws.Bot.SetCollect(key, true)
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "collect.on", ws.Config.CollectWindow))
+	case "off":
+		ws.Bot.SetCollect(key, false)
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "collect.off"))
+	default:
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "collect.usage"))
+	}
+}
+
+// handleVerbose handles the /verbose command
+func (m *Manager) handleVerbose(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	args := strings.Fields(text)
+	if len(args) == 1 {
+		state := "off"
+		if ws.Bot.IsVerbose(key) {
+			state = "on"
+		}
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "verbose.state", state))
+	}
+
+	switch args[1] {
+	case "on":
+		ws.Bot.SetVerbose(key, true)
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "verbose.on"))
+	case "off":
+		ws.Bot.SetVerbose(key, false)
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "verbose.off"))
+	default:
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "verbose.usage"))
+	}
+}
+
+// handleDryRun handles the /dryrun command
+func (m *Manager) handleDryRun(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	args := strings.Fields(text)
+	if len(args) == 1 {
+		state := "off"
+		if ws.Bot.IsDryRun(key) {
+			state = "on"
+		}
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "dryrun.state", state))
+	}
+
+	switch args[1] {
+	case "on":
+		ws.Bot.SetDryRun(key, true)
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "dryrun.on"))
+	case "off":
+		ws.Bot.SetDryRun(key, false)
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "dryrun.off"))
+	default:
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "dryrun.usage"))
+	}
+}
+
+// handleLang handles the /lang command
+func (m *Manager) handleLang(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	available := strings.Join(supportedLangs(), ", ")
+	args := strings.Fields(text)
+	if len(args) == 1 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "lang.current", ws.Bot.GetLang(key), available))
+	}
+
+	if err := ws.Bot.SetLang(key, args[1]); err != nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "lang.unknown", args[1], available))
+	}
+	return sendText(ctx, ws, chatID, ws.Bot.t(key, "lang.changed", args[1]))
 }
 
 // handleStats handles the /stats command
-func (m *Manager) handleStats(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
-	stats, err := ws.Bot.GetStats(chatID)
+func (m *Manager) handleStats(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	args := strings.Fields(text)
+	if len(args) > 1 {
+		if args[1] != "reset" {
+			return sendText(ctx, ws, chatID, ws.Bot.t(key, "stats.usage"))
+		}
+		ws.Bot.ResetStats(key)
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "stats.reset"))
+	}
+
+	stats, err := ws.Bot.GetStats(key)
 	if err != nil {
-		_, err := ws.TgBot.SendMessage(ctx, tu.Message(
-			tu.ID(chatID),
-			fmt.Sprintf("❌ %v", err),
-		))
-		return err
+		return sendText(ctx, ws, chatID, fmt.Sprintf("❌ %v", err))
 	}
 
-	_, err = ws.TgBot.SendMessage(ctx, tu.Message(
-		tu.ID(chatID),
-		fmt.Sprintf("📊 **Statistics**\n```\n%s\n```", stats),
-	).WithParseMode(telego.ModeMarkdown))
-	return err
+	msg := "📊 *" + escapeMarkdownV2(ws.Bot.t(key, "stats.title")) + "*\n"
+	if chat, ok := ws.Bot.GetChatStats(key); ok {
+		msg += escapeMarkdownV2(ws.Bot.t(key, "stats.chat",
+			chat.Since.Format("2006-01-02 15:04"), chat.Prompts, chat.Failures, chat.Duration.Round(time.Second))) + "\n"
+		msg += escapeMarkdownV2(formatUsage(ws, key, chat)) + "\n"
+	}
+	return sendFormatted(ctx, ws, chatID, msg+fmt.Sprintf("```\n%s\n```", escapeMarkdownV2Code(stats)))
+}
+
+// formatUsage describes the tokens a chat used and, if rates are configured,
+// their estimated cost
+func formatUsage(ws *WorkspaceBot, key sessionKey, stats ChatStats) string {
+	if !stats.UsageReported {
+		return ws.Bot.t(key, "stats.usage_unavailable")
+	}
+	text := ws.Bot.t(key, "stats.tokens", stats.InputTokens, stats.OutputTokens)
+	inRate, outRate := ws.Config.CostPerInputToken, ws.Config.CostPerOutputToken
+	if inRate > 0 || outRate > 0 {
+		text += ws.Bot.t(key, "stats.cost", stats.EstimatedCost(inRate, outRate))
+	}
+	return text
+}
+
+// handleStop handles the /stop command
+func (m *Manager) handleStop(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	ws.Bot.DropQueued(key)
+	ws.Bot.CancelRun(key)
+	ws.Bot.CancelTasks(key)
+	ws.Bot.ClearSession(key)
+
+	return sendText(ctx, ws, chatID, ws.Bot.t(key, "stop.done"))
+}
+
+// handleHistory handles the /history command
+func (m *Manager) handleHistory(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	n := 5
+	if args := strings.Fields(text); len(args) > 1 {
+		parsed, err := strconv.Atoi(args[1])
+		if err != nil || parsed < 1 {
+			return sendText(ctx, ws, chatID, ws.Bot.t(key, "history.usage"))
+		}
+		n = parsed
+	}
+
+	history := ws.Bot.GetHistory(key, n)
+	if len(history) == 0 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "history.none"))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(ws.Bot.t(key, "history.title") + "\n")
+	for _, exchange := range history {
+		sb.WriteString(fmt.Sprintf("\n[%s]\n❓ %s\n💬 %s\n", exchange.Time.Format("15:04"), exchange.Prompt, exchange.Response))
+	}
+
+	return sendChunks(ctx, ws, chatID, sb.String())
+}
+
+// handleResend handles the /resend command, sending the chat's latest
+// response again
+func (m *Manager) handleResend(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	output, ok := ws.Bot.GetLastOutput(key)
+	if !ok {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "resend.none"))
+	}
+	if threshold := ws.Config.FileThresholdBytes; threshold > 0 && len(output) > threshold {
+		return ws.sendDocumentWithRetry(ctx, chatID, "response.txt", []byte(output), "")
+	}
+	return sendChunks(ctx, ws, chatID, output)
+}
+
+// handleWorkspaces handles the /workspaces command
+func (m *Manager) handleWorkspaces(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	var sb strings.Builder
+	sb.WriteString("🗂 *" + escapeMarkdownV2(ws.Bot.t(key, "workspaces.title")) + "*\n\n")
+	for _, other := range ws.group.workspaces {
+		marker := "•"
+		if other == ws {
+			marker = "✅"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s \\- %s\n", marker, escapeMarkdownV2(other.Config.Name), mdCode(other.Config.WorkingDir)))
+	}
+	if len(ws.group.workspaces) > 1 {
+		sb.WriteString("\n" + escapeMarkdownV2(ws.Bot.t(key, "workspaces.hint")))
+	}
+
+	return sendFormatted(ctx, ws, chatID, sb.String())
+}
+
+// handleWorkspace handles the /workspace command
+func (m *Manager) handleWorkspace(ctx context.Context, ws *WorkspaceBot, chatID, userID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "workspace.current", ws.Config.Name))
+	}
+
+	target := ws.group.find(args[1])
+	if target == nil {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "workspace.unknown", args[1], strings.Join(ws.group.names(), ", ")))
+	}
+	if !target.Bot.IsAllowed(chatID) || !target.Bot.IsAuthorized(userID) {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "workspace.denied", target.Config.Name))
+	}
+
+	ws.group.selectWorkspace(chatID, target)
+	return sendText(ctx, ws, chatID, ws.Bot.t(key, "workspace.switched", target.Config.Name, target.Config.WorkingDir))
+}
+
+// handleHelp handles the /help and /start commands
+func (m *Manager) handleHelp(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	return sendFormatted(ctx, ws, chatID, helpText(ws.Bot.GetLang(chatKey(ctx, chatID)), ws.Bot.CommandEnabled))
+}
+
+// handleStart handles the /start command. A chat starting out without a
+// session is greeted with the welcome message once; afterwards /start acts
+// like /help.
+func (m *Manager) handleStart(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	if ws.Bot.GetSessionID(key) != "" || !ws.Bot.MarkGreeted(key) {
+		return m.handleHelp(ctx, ws, chatID)
+	}
+
+	// New chats are greeted with start.welcome unless welcome_message is set
+	welcome := ws.Config.WelcomeMessage
+	if welcome == "" {
+		welcome = ws.Bot.t(key, "start.welcome")
+	}
+	ws.Bot.NewSession(key)
+	return sendFormatted(ctx, ws, chatID, escapeMarkdownV2(welcome)+"\n\n"+helpText(ws.Bot.GetLang(key), ws.Bot.CommandEnabled))
+}
+
+// handleCancel handles the /cancel command
+func (m *Manager) handleCancel(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	canceled := ws.Bot.CancelRun(key)
+	if ws.Bot.CancelTasks(key) > 0 {
+		canceled = true
+	}
+	if !canceled {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "cancel.none"))
+	}
+
+	return sendText(ctx, ws, chatID, ws.Bot.t(key, "cancel.running"))
+}
+
+// logCommand logs a finished CLI invocation. Only sizes are logged, never
+// the prompt or output themselves.
+func (m *Manager) logCommand(ws *WorkspaceBot, chatID int64, cli, model, prompt string, result CommandResult, duration time.Duration) {
+	attrs := []any{
+		"chat_id", chatID,
+		"workspace", ws.Config.Name,
+		"cli", cli,
+		"model", model,
+		"prompt_length", len(prompt),
+		"duration_ms", duration.Milliseconds(),
+		"exit_code", result.ExitCode,
+		"output_length", len(result.Stdout),
+	}
+	if result.Err != nil {
+		attrs = append(attrs, "error", result.Err.Error())
+	}
+	m.logger.Info("command executed", attrs...)
 }
 
 // handleMessage handles regular messages
-func (m *Manager) handleMessage(ctx context.Context, ws *WorkspaceBot, chatID int64, prompt, imagePath string) error {
+func (m *Manager) handleMessage(ctx context.Context, ws *WorkspaceBot, chatID int64, prompt string, files []attachment) error {
+	return m.handlePrompt(ctx, ws, chatID, prompt, files, true)
+}
+
+// handlePrompt runs a prompt with the conversation's CLI. With useCache, a
+// text prompt sent again within cache_ttl is answered from the cache.
+func (m *Manager) handlePrompt(ctx context.Context, ws *WorkspaceBot, chatID int64, prompt string, files []attachment, useCache bool) error {
 	if prompt == "" {
 		return nil
 	}
+	key := chatKey(ctx, chatID)
+	if len(prompt) > ws.Config.MaxPromptBytes {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.too_long", len(prompt), ws.Config.MaxPromptBytes))
+	}
+	ws.Bot.SetLastPrompt(key, prompt, files)
+
+	// "!<cli> prompt" runs just this prompt with another CLI
+	override, prompt, err := ws.Bot.CLIOverride(prompt)
+	if err != nil {
+		return sendText(ctx, ws, chatID, fmt.Sprintf("❌ %v", err))
+	}
+	if prompt == "" {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.override_usage", ws.Config.CLIPrefix, override))
+	}
+	if override == ws.Bot.GetCLI(key) {
+		override = "" // Already the chat's CLI, so keep the session
+	}
+
+	// Wait for the previous command in this chat to finish
+	release, err := ws.Bot.AcquireRun(ctx, key, func() {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.queued"))
+	})
+	if errors.Is(err, ErrQueueFull) {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.queue_full"))
+	}
+	if errors.Is(err, ErrDropped) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if override == "" && ws.Bot.ExpireIdleSession(key) {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "session.expired"))
+	}
+
+	// Snapshot the chat state the command is built from
+	cli := ws.Bot.GetCLI(key)
+	model := ws.Bot.GetModel(key)
+	prevSessionID := ws.Bot.GetSessionID(key)
+	workDir := ws.Bot.GetWorkDir(key)
 
 	// Build command
-	cmd := ws.Bot.BuildCommand(chatID, prompt, imagePath)
+	filePaths := make([]string, len(files))
+	for i, file := range files {
+		filePaths[i] = file.Path
+	}
+	cmd, stdin := ws.Bot.BuildCommand(key, prompt, filePaths)
+	if override != "" {
+		// One-off runs neither use nor replace the chat's session
+		model = ws.Bot.OneOffModel(override)
+		cli, cmd, stdin = ws.Bot.BuildOneOffCommand(override, model, prompt, filePaths)
+		prevSessionID = ""
+	}
 	if cmd == nil {
-		_, _ = ws.TgBot.SendMessage(ctx, tu.Message(
-			tu.ID(chatID),
-			"❌ Failed to build command",
-		))
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.no_command"))
 		return nil
 	}
+	if ws.Bot.IsDryRun(key) {
+		return sendChunks(ctx, ws, chatID, formatDryRun(cmd, stdin, workDir))
+	}
+
+	// Replies depend on attachments too, so only text prompts are cached
+	cacheable := len(files) == 0
+	scope := cacheScope{key: key, workDir: workDir, sessionID: prevSessionID, cli: cli, model: model}
+	if cacheable && useCache {
+		if reply, age, ok := ws.Bot.CachedReply(scope, prompt); ok {
+			ws.Bot.RecordExchange(key, prompt, reply)
+			if err := sendChunks(ctx, ws, chatID, reply); err != nil {
+				return err
+			}
+			return sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.cached", age.Round(time.Second)))
+		}
+	}
+	exec := ws.Bot.GetExecutor(cli)
+
+	// Acknowledge the prompt right away; the placeholder is edited into the answer
+	streamer := newMessageStreamer(ctx, ws, chatID, func(output string) string {
+		return cleanOutput(ws, previewOutput(exec, output))
+	})
 
 	// Send typing action periodically while processing
 	typingCtx, cancelTyping := context.WithCancel(ctx)
 	defer cancelTyping()
-	go func() {
-		ticker := time.NewTicker(4 * time.Second)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-typingCtx.Done():
-				return
-			case <-ticker.C:
-				_ = ws.TgBot.SendChatAction(ctx, &telego.SendChatActionParams{
-					ChatID: tu.ID(chatID),
-					Action: telego.ChatActionTyping,
-				})
-			}
-		}
-	}()
+	if !ws.Config.DisableTypingAction {
+		go sendTypingAction(typingCtx, ws, chatID)
+	}
+
+	// Execute command with working directory (cancelable via /cancel),
+	// streaming output into the chat as it arrives
+	runCtx, finishRun := ws.Bot.StartRun(ctx, key)
+	defer finishRun()
+
+	// Wait for a free CLI slot in this workspace
+	releaseSlot, err := m.acquireSlot(runCtx, ws, func() {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.wait_slot"))
+	})
+	if err != nil {
+		return streamer.Finish(ws.Bot.t(key, "prompt.canceled"))
+	}
 
-	// Execute command with working directory
-	output := runCommandWithDir(cmd, ws.Config.WorkingDir, ws.Config.CommandTimeout)
+	promptsTotal.WithLabelValues(ws.Config.Name, cli).Inc()
+	activeCommands.WithLabelValues(ws.Config.Name).Inc()
+	run, finishDirRun := ws.Bot.TrackRun(workDir)
+	started := run.started
+	cmdCtx, cancelCmd := context.WithTimeout(runCtx, ws.Config.CommandTimeout)
+	result := runCommandWithDir(cmdCtx, cmd, workDir, ws.Config.Env, stdin, streamer.Append)
+	cancelCmd()
+	duration := time.Since(started)
+	activeCommands.WithLabelValues(ws.Config.Name).Dec()
+	releaseSlot()
+	finishRun()
+	m.logCommand(ws, chatID, cli, model, prompt, result, duration)
+	observeCommand(ws.Config.Name, cli, result, duration)
+	ws.Bot.RecordRun(key, result.Err != nil || result.ExitCode != 0, duration)
 
 	// Save session ID (from raw output before JSON parsing)
-	ws.Bot.UpdateSessionFromOutput(chatID, ws.Bot.GetCLI(chatID), output)
+	sessionLost := ws.Bot.UpdateSessionFromOutput(key, cli, prevSessionID, result.Stdout, run)
+	finishDirRun()
+
+	output := applyPostProcess(ctx, ws, cleanOutput(ws, formatOutput(exec, result.Stdout)))
+	result.Stderr = cleanOutput(ws, result.Stderr)
+
+	reply := formatCommandResult(result, output, ws.Config.CommandTimeout)
+	if cacheable && result.Err == nil && result.ExitCode == 0 {
+		ws.Bot.CacheReply(scope, prompt, reply)
+	}
+	if sessionLost {
+		reply += "\n\n" + ws.Bot.t(key, "session.lost")
+	}
+	ws.Bot.RecordExchange(key, prompt, reply)
 
-	// For OpenCode, extract text from JSON output
-	if ws.Bot.GetCLI(chatID) == "opencode" {
-		output = extractTextFromOpenCodeJSON(output)
+	// Send final result (chunked)
+	if err := streamer.Finish(reply); err != nil {
+		return err
+	}
+
+	if tracer, ok := exec.(executor.ToolTracer); ok && ws.Config.ShowToolTrace {
+		if trace := summarizeToolCalls(tracer.ToolCalls(result.Stdout)); trace != "" {
+			if err := sendText(ctx, ws, chatID, trace); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ws.Config.AttachChangedFiles {
+		if err := sendChangedFiles(ctx, ws, chatID, workDir, started); err != nil {
+			return err
+		}
 	}
 
-	// Send result (chunked)
-	return sendChunks(ctx, ws.TgBot, chatID, output)
+	if ws.Bot.IsVerbose(key) {
+		return sendFormatted(ctx, ws, chatID, formatRunDetails(cmd, stdin, result, duration))
+	}
+	return nil
+}
+
+// sendTypingAction shows the typing indicator in the chat until ctx is done
+func sendTypingAction(ctx context.Context, ws *WorkspaceBot, chatID int64) {
+	ticker := time.NewTicker(4 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = ws.TgBot.SendChatAction(ctx, &telego.SendChatActionParams{
+				ChatID:          tu.ID(chatID),
+				MessageThreadID: threadID(ctx),
+				Action:          telego.ChatActionTyping,
+			})
+		}
+	}
 }
 
-// sendChunks splits and sends long messages
-func sendChunks(ctx context.Context, bot *telego.Bot, chatID int64, text string) error {
-	const maxMessageLength = 4000
+// maxMessageLength is the maximum length of a single message sent to
+// Telegram, in UTF-16 code units. The API allows 4096; the rest is margin.
+const maxMessageLength = 4000
 
+// minSplitLength is the smallest chunk size sendSplitChunks splits down to
+const minSplitLength = 500
+
+// sendChunks splits and sends long messages, formatting code blocks
+func sendChunks(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
 	// Trim whitespace and check if empty
 	trimmedText := strings.TrimSpace(text)
 	if trimmedText == "" {
-		_, err := bot.SendMessage(ctx, tu.Message(
-			tu.ID(chatID),
-			"(empty response)",
-		))
-		return err
+		return sendText(ctx, ws, chatID, ws.Bot.t(chatKey(ctx, chatID), "reply.empty"))
 	}
 
-	chunks := chunkString(trimmedText, maxMessageLength)
-	for _, chunk := range chunks {
+	return sendSplitChunks(ctx, ws, chatID, trimmedText, maxMessageLength)
+}
+
+// sendSplitChunks sends text in chunks of at most size. A chunk Telegram
+// still rejects as too long is split again at half the size.
+func sendSplitChunks(ctx context.Context, ws *WorkspaceBot, chatID int64, text string, size int) error {
+	for _, chunk := range chunkString(text, size) {
 		// Ensure chunk is not empty after trimming
 		if strings.TrimSpace(chunk) == "" {
 			continue
 		}
-		_, err := bot.SendMessage(ctx, tu.Message(
-			tu.ID(chatID),
-			chunk,
-		))
+		_, err := sendOutputChunk(ctx, ws, chatID, chunk)
+		if isTooLongError(err) && size > minSplitLength {
+			err = sendSplitChunks(ctx, ws, chatID, chunk, size/2)
+		}
 		if err != nil {
 			return err
 		}
@@ -179,7 +794,14 @@ func sendChunks(ctx context.Context, bot *telego.Bot, chatID int64, text string)
 	return nil
 }
 
-// chunkString splits a string into chunks of specified size
+// codeFence opens and closes Markdown fenced code blocks
+const codeFence = "```"
+
+// chunkString splits a string into chunks of specified size, measured in
+// UTF-16 code units like Telegram's message limit. Fenced code
+// blocks cut by a chunk boundary are closed at the end of the chunk and
+// reopened, with the same language tag, at the start of the next one, so
+// every chunk is valid Markdown on its own.
 func chunkString(s string, size int) []string {
 	if len(s) <= size {
 		return []string{s}
@@ -187,80 +809,413 @@ func chunkString(s string, size int) []string {
 
 	var chunks []string
 	runes := []rune(s)
+	reopen := "" // Fence line reopening a code block cut by the previous chunk
 
 	for len(runes) > 0 {
-		if len(runes) < size {
-			size = len(runes)
+		budget := size - utf16Len([]rune(reopen))
+		if utf16Len(runes) <= budget {
+			chunks = append(chunks, reopen+string(runes))
+			break
 		}
 
-		// Try to cut at word boundary
-		cutPoint := size
-		for i := size - 1; i > size*3/4; i-- {
-			if i < len(runes) && (runes[i] == '\n' || runes[i] == ' ') {
-				cutPoint = i
-				break
-			}
+		// Keep room to close a code block left open by the cut
+		budget -= len("\n" + codeFence)
+		if budget < 2 {
+			budget = 2 // Room for a surrogate pair
 		}
 
-		chunks = append(chunks, string(runes[:cutPoint]))
+		cutPoint := findCutPoint(runes, runesWithin(runes, budget))
+		chunk := reopen + string(runes[:cutPoint])
 		runes = runes[cutPoint:]
+
+		reopen = ""
+		if lang, open := openCodeFence(chunk); open {
+			if !strings.HasSuffix(chunk, "\n") {
+				chunk += "\n"
+			}
+			chunk += codeFence
+			reopen = codeFence + lang + "\n"
+			// The reopened fence already starts a new line
+			if len(runes) > 0 && runes[0] == '\n' {
+				runes = runes[1:]
+			}
+		} else if len(runes) > 0 && runes[0] == ' ' {
+			// Don't start the next chunk with the space that was cut at
+			runes = runes[1:]
+		} else {
+			// Don't start the next chunk with the break that was cut at
+			for len(runes) > 0 && runes[0] == '\n' {
+				runes = runes[1:]
+			}
+		}
+
+		chunks = append(chunks, chunk)
 	}
 
 	return chunks
 }
 
+// utf16Len returns the length of runes in UTF-16 code units
+func utf16Len(runes []rune) int {
+	n := 0
+	for _, r := range runes {
+		n += runeUnits(r)
+	}
+	return n
+}
+
+// runesWithin returns how many leading runes fit into units UTF-16 code units
+func runesWithin(runes []rune, units int) int {
+	for i, r := range runes {
+		units -= runeUnits(r)
+		if units < 0 {
+			return i
+		}
+	}
+	return len(runes)
+}
+
+// runeUnits returns the number of UTF-16 code units encoding r
+func runeUnits(r rune) int {
+	if utf16.RuneLen(r) == 2 {
+		return 2 // Surrogate pair, e.g. most emoji
+	}
+	return 1
+}
+
+// findCutPoint returns where to cut runes to get a chunk of at most size
+// runes. Within the last half of the chunk it prefers, in order, a paragraph
+// break, a line break and a space, and cuts hard at size if there is none.
+func findCutPoint(runes []rune, size int) int {
+	if len(runes) <= size {
+		return len(runes)
+	}
+
+	minCut := size / 2
+	for _, boundary := range []func(i int) bool{
+		func(i int) bool { return runes[i] == '\n' && runes[i+1] == '\n' },
+		func(i int) bool { return runes[i] == '\n' },
+		func(i int) bool { return runes[i] == ' ' },
+	} {
+		for i := size - 1; i > minCut; i-- {
+			if boundary(i) {
+				return i
+			}
+		}
+	}
+	return size
+}
+
+// openCodeFence reports whether text ends inside a fenced code block and, if
+// so, the info string (language tag) of that block. Inside a block only a
+// bare fence closes it, so a line like "```go" in the code doesn't.
+func openCodeFence(text string) (lang string, open bool) {
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, codeFence) {
+			continue
+		}
+		info := fenceInfo(trimmed)
+		switch {
+		case !open:
+			open, lang = true, info
+		case info == "":
+			open, lang = false, ""
+		}
+	}
+	return lang, open
+}
+
+// fenceInfo returns the info string of a fence line, e.g. "python" for
+// "```python"
+func fenceInfo(line string) string {
+	return strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "`"))
+}
+
 // handlePhotoMessage handles image messages
 func (m *Manager) handlePhotoMessage(ctx context.Context, ws *WorkspaceBot, message *telego.Message) error {
 	chatID := message.Chat.ID
 
-	// Select largest image
-	photoSizes := message.Photo
-	largestPhoto := photoSizes[len(photoSizes)-1]
+	// Forwarded or malformed messages may come without photo sizes
+	if len(message.Photo) == 0 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(chatKey(ctx, chatID), "image.missing"))
+	}
+
+	// Albums arrive as one update per photo, process them together
+	if message.MediaGroupID != "" {
+		m.bufferMediaGroup(ctx, ws, message)
+		return nil
+	}
 
-	// Get file info
-	file, err := ws.TgBot.GetFile(ctx, &telego.GetFileParams{FileID: largestPhoto.FileID})
+	photo, err := downloadPhoto(ctx, ws, message)
+	if errors.Is(err, errImageTooLarge) {
+		return nil
+	}
 	if err != nil {
-		_, _ = ws.TgBot.SendMessage(ctx, tu.Message(
-			tu.ID(chatID),
-			"❌ Failed to get image info",
-		))
 		return err
 	}
+	defer os.Remove(photo.Path) // Clean up temp file
+
+	// Process prompt
+	prompt := message.Caption
+	if prompt == "" {
+		prompt = ws.Config.DefaultImagePrompt
+	}
+	if prompt == "" {
+		prompt = "Analyze this image"
+	}
+
+	return m.handleMessage(ctx, ws, chatID, prompt, []attachment{photo})
+}
+
+// errImageTooLarge is returned by downloadPhoto when every size of a photo
+// exceeds the workspace's MaxImageBytes
+var errImageTooLarge = errors.New("image too large")
+
+// selectPhotoSize returns the largest photo size of at most maxBytes (0 means
+// no limit). Sizes of unknown file size are assumed to fit.
+func selectPhotoSize(sizes []telego.PhotoSize, maxBytes int64) (telego.PhotoSize, bool) {
+	var best telego.PhotoSize
+	found := false
+	for _, size := range sizes {
+		if maxBytes > 0 && int64(size.FileSize) > maxBytes {
+			continue
+		}
+		if !found || size.Width*size.Height > best.Width*best.Height {
+			best = size
+			found = true
+		}
+	}
+	return best, found
+}
+
+// downloadPhoto downloads the largest size of a message's photo allowed by
+// MaxImageBytes to a temp file, notifying the user on failure
+func downloadPhoto(ctx context.Context, ws *WorkspaceBot, message *telego.Message) (attachment, error) {
+	chatID := message.Chat.ID
+	key := chatKey(ctx, chatID)
+
+	// Select largest image within the size limit
+	photo, ok := selectPhotoSize(message.Photo, ws.Config.MaxImageBytes)
+	if !ok {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "image.too_large"))
+		return attachment{}, errImageTooLarge
+	}
+
+	// Get file info
+	file, err := ws.TgBot.GetFile(ctx, &telego.GetFileParams{FileID: photo.FileID})
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "media.info_failed", ws.Bot.t(key, "media.image")))
+		return attachment{}, err
+	}
 
 	// Download to temp file
-	tempPath := fmt.Sprintf("/tmp/telecode_img_%d_%d.jpg", chatID, time.Now().Unix())
-	if err := downloadFile(ws.Config.BotToken, file.FilePath, tempPath); err != nil {
-		_, _ = ws.TgBot.SendMessage(ctx, tu.Message(
-			tu.ID(chatID),
-			"❌ Failed to download image",
-		))
+	const pattern = "telecode_img_*.jpg"
+	tempPath, err := downloadToTemp(ctx, ws, file.FilePath, pattern)
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "media.download_failed", ws.Bot.t(key, "media.image")))
+		return attachment{}, err
+	}
+
+	return attachment{Path: tempPath, FileID: photo.FileID, Pattern: pattern}, nil
+}
+
+// handleDocumentMessage handles document uploads, passing the file to the CLI
+func (m *Manager) handleDocumentMessage(ctx context.Context, ws *WorkspaceBot, message *telego.Message) error {
+	chatID := message.Chat.ID
+	key := chatKey(ctx, chatID)
+	document := message.Document
+
+	// Check size before downloading
+	if document.FileSize > ws.Config.MaxDocumentBytes {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "document.too_large", ws.Config.MaxDocumentBytes/1024))
+	}
+
+	// Reject files that are known to be binary
+	mimeKnown := document.MimeType != "" && document.MimeType != "application/octet-stream"
+	if mimeKnown && !isTextMimeType(document.MimeType) {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "document.not_text"))
+	}
+
+	// Get file info
+	file, err := ws.TgBot.GetFile(ctx, &telego.GetFileParams{FileID: document.FileID})
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "media.info_failed", ws.Bot.t(key, "media.file")))
+		return err
+	}
+
+	// Download to temp file, keeping the original name so the CLI sees it
+	fileName := filepath.Base(document.FileName)
+	if fileName == "." || fileName == string(filepath.Separator) {
+		fileName = "document.txt"
+	}
+	pattern := "telecode_doc_*_" + strings.ReplaceAll(fileName, "*", "_")
+	tempPath, err := downloadToTemp(ctx, ws, file.FilePath, pattern)
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "media.download_failed", ws.Bot.t(key, "media.file")))
 		return err
 	}
 	defer os.Remove(tempPath) // Clean up temp file
 
+	// Sniff the content when Telegram didn't report a useful MIME type
+	if !mimeKnown && !isTextFile(tempPath) {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "document.not_text"))
+	}
+
 	// Process prompt
 	prompt := message.Caption
 	if prompt == "" {
-		prompt = "Analyze this image"
+		prompt = ws.Config.DefaultDocumentPrompt
+	}
+	if prompt == "" {
+		prompt = "Review this file."
 	}
 
-	return m.handleMessage(ctx, ws, chatID, prompt, tempPath)
+	return m.handleMessage(ctx, ws, chatID, prompt, []attachment{{Path: tempPath, FileID: document.FileID, Pattern: pattern}})
+}
+
+// handleVoiceMessage handles voice messages by transcribing them into a prompt
+func (m *Manager) handleVoiceMessage(ctx context.Context, ws *WorkspaceBot, message *telego.Message) error {
+	chatID := message.Chat.ID
+
+	if len(ws.Config.TranscribeCommand) == 0 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(chatKey(ctx, chatID), "voice.not_configured"))
+	}
+	return m.handleTranscription(ctx, ws, chatID, message.Voice.FileID, "telecode_voice_*.oga", "media.voice")
 }
 
+// handleAudioMessage handles audio files, such as forwarded recordings or
+// podcasts, by transcribing them into a prompt like voice messages
+func (m *Manager) handleAudioMessage(ctx context.Context, ws *WorkspaceBot, message *telego.Message) error {
+	chatID := message.Chat.ID
+	key := chatKey(ctx, chatID)
+	audio := message.Audio
+
+	if len(ws.Config.TranscribeCommand) == 0 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "voice.not_configured"))
+	}
+
+	// Check limits before downloading
+	if audio.FileSize > ws.Config.MaxAudioBytes {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "audio.too_large", ws.Config.MaxAudioBytes/1024))
+	}
+	if limit := ws.Config.MaxAudioDuration; limit > 0 && time.Duration(audio.Duration)*time.Second > limit {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "audio.too_long", limit))
+	}
+
+	// Transcription tools often pick the decoder by extension
+	ext := filepath.Ext(filepath.Base(audio.FileName))
+	if ext == "" || strings.Contains(ext, "*") {
+		ext = ".mp3"
+	}
+	return m.handleTranscription(ctx, ws, chatID, audio.FileID, "telecode_audio_*"+ext, "media.audio")
+}
+
+// handleTranscription downloads the audio file fileID into a temp file named
+// after pattern, transcribes it and runs the transcript as a prompt. whatID
+// is the message naming the file in error messages.
+func (m *Manager) handleTranscription(ctx context.Context, ws *WorkspaceBot, chatID int64, fileID, pattern, whatID string) error {
+	key := chatKey(ctx, chatID)
+	what := ws.Bot.t(key, whatID)
+
+	// Get file info
+	file, err := ws.TgBot.GetFile(ctx, &telego.GetFileParams{FileID: fileID})
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "media.info_failed", what))
+		return err
+	}
+
+	// Download to temp file
+	tempPath, err := downloadToTemp(ctx, ws, file.FilePath, pattern)
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "media.download_failed", what))
+		return err
+	}
+	defer os.Remove(tempPath) // Clean up temp file
+
+	// Transcribe into a prompt
+	prompt, err := transcribeAudio(ctx, ws.Config.TranscribeCommand, tempPath, ws.Config.CommandTimeout)
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "voice.transcribe_failed", what))
+		return err
+	}
+	if prompt == "" {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "voice.no_speech", what))
+	}
+
+	// Show what was understood before running the CLI
+	_ = sendText(ctx, ws, chatID, "🎙️ "+prompt)
+
+	return m.handleMessage(ctx, ws, chatID, prompt, nil)
+}
+
+// attachment is a file from Telegram passed to the CLI along with a prompt
+type attachment struct {
+	Path    string // Downloaded temp file
+	FileID  string // Telegram file ID, to download the file again
+	Pattern string // Temp file name pattern
+}
+
+// redownload downloads an attachment again into a new temp file
+func redownload(ctx context.Context, ws *WorkspaceBot, file attachment) (attachment, error) {
+	info, err := ws.TgBot.GetFile(ctx, &telego.GetFileParams{FileID: file.FileID})
+	if err != nil {
+		return attachment{}, err
+	}
+	file.Path, err = downloadToTemp(ctx, ws, info.FilePath, file.Pattern)
+	if err != nil {
+		return attachment{}, err
+	}
+	return file, nil
+}
+
+// downloadToTemp downloads a Telegram file into a new file in the workspace
+// temp dir, named after pattern as with os.CreateTemp. The file is written
+// through the handle os.CreateTemp returned, so concurrent downloads can
+// never end up sharing a path.
+func downloadToTemp(ctx context.Context, ws *WorkspaceBot, filePath, pattern string) (string, error) {
+	tmp, err := os.CreateTemp(ws.Config.TempDir, pattern)
+	if err != nil {
+		return "", err
+	}
+
+	err = downloadFile(ctx, ws.Config.BotToken, filePath, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// fileURLFormat is the URL of a Telegram file, given the bot token and the
+// file path
+var fileURLFormat = "https://api.telegram.org/file/bot%s/%s"
+
 // downloadFile downloads a file from Telegram
-func downloadFile(botToken, filePath, localPath string) error {
-	url := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", botToken, filePath)
-	resp, err := http.Get(url)
+func downloadFile(ctx context.Context, botToken, filePath string, out io.Writer) error {
+	fileURL := fmt.Sprintf(fileURLFormat, botToken, filePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	out, err := os.Create(localPath)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
+		// The error's URL contains the bot token, keep it out of logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("download failed: %w", urlErr.Err)
+		}
 		return err
 	}
-	defer out.Close()
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
 
 	_, err = io.Copy(out, resp.Body)
 	return err
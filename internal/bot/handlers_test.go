@@ -0,0 +1,500 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/mymmrac/telego"
+
+	"telecode/internal/config"
+)
+
+func TestHandleStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		session string
+		lang    string
+		want    []string
+	}{
+		{
+			name: "new chat",
+			want: []string{"📊 *Current Status*", "\\- CLI: `claude`", "\\- Session: `none`", "\\- State: idle"},
+		},
+		{
+			name:    "with session",
+			session: "abc-123",
+			want:    []string{"\\- Session: `abc-123`"},
+		},
+		{
+			name: "korean",
+			lang: "ko",
+			want: []string{"📊 *현재 상태*", "\\- CLI: `claude`", "\\- 세션: `none`", "\\- 상태: 대기 중"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, fake := newTestWorkspace(t, config.WorkspaceConfig{})
+			if tt.session != "" {
+				ws.Bot.sessionMgr.Set(sessionKey{chatID: 1}, tt.session)
+			}
+			if tt.lang != "" {
+				if err := ws.Bot.SetLang(sessionKey{chatID: 1}, tt.lang); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := (&Manager{}).handleStatus(context.Background(), ws, 1); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(fake.sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(fake.sent))
+			}
+			msg := fake.sent[0]
+			if msg.ParseMode != telego.ModeMarkdownV2 {
+				t.Errorf("parse mode = %q, want %q", msg.ParseMode, telego.ModeMarkdownV2)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(msg.Text, want) {
+					t.Errorf("status %q does not contain %q", msg.Text, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleCLI(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		installed bool
+		want      string
+		keyboard  bool
+		wantCLI   string
+	}{
+		{
+			name:     "show current",
+			text:     "/cli",
+			want:     "📋 Current CLI: `claude`",
+			keyboard: true,
+			wantCLI:  "claude",
+		},
+		{
+			name:      "switch",
+			text:      "/cli opencode",
+			installed: true,
+			want:      "✅ CLI changed to: `opencode` \\(session reset\\)",
+			wantCLI:   "opencode",
+		},
+		{
+			name:    "not installed",
+			text:    "/cli opencode",
+			want:    "❌ CLI 'opencode' is not installed",
+			wantCLI: "claude",
+		},
+		{
+			name:    "unsupported",
+			text:    "/cli vim",
+			want:    "❌ Unsupported CLI\\. Use: claude \\| opencode",
+			wantCLI: "claude",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.installed {
+				installFakeCLI(t, "opencode")
+			} else {
+				t.Setenv("PATH", t.TempDir())
+			}
+			ws, fake := newTestWorkspace(t, config.WorkspaceConfig{})
+
+			if err := (&Manager{}).handleCLI(context.Background(), ws, 1, tt.text); err != nil {
+				t.Fatal(err)
+			}
+
+			if len(fake.sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(fake.sent))
+			}
+			msg := fake.sent[0]
+			if msg.Text != tt.want {
+				t.Errorf("reply = %q, want %q", msg.Text, tt.want)
+			}
+			if got := msg.ReplyMarkup != nil; got != tt.keyboard {
+				t.Errorf("keyboard = %v, want %v", got, tt.keyboard)
+			}
+			if got := ws.Bot.GetCLI(sessionKey{chatID: 1}); got != tt.wantCLI {
+				t.Errorf("CLI = %q, want %q", got, tt.wantCLI)
+			}
+		})
+	}
+}
+
+func TestSendChunks(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "empty",
+			text: " \n\t",
+			want: []string{"\\(empty response\\)"},
+		},
+		{
+			name: "escaped",
+			text: "done. see file_name.go!",
+			want: []string{"done\\. see file\\_name\\.go\\!"},
+		},
+		{
+			name: "split",
+			text: strings.Repeat("a", 3000) + "\n\n" + strings.Repeat("b", 3000),
+			want: []string{strings.Repeat("a", 3000), strings.Repeat("b", 3000)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, fake := newTestWorkspace(t, config.WorkspaceConfig{})
+
+			if err := sendChunks(context.Background(), ws, 1, tt.text); err != nil {
+				t.Fatal(err)
+			}
+
+			got := fake.texts()
+			if len(got) != len(tt.want) {
+				t.Fatalf("sent %d messages, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("message %d = %q, want %q", i, truncateText(got[i], 80), truncateText(tt.want[i], 80))
+				}
+			}
+		})
+	}
+}
+
+func TestSendChunksResplitsTooLong(t *testing.T) {
+	ws, fake := newTestWorkspace(t, config.WorkspaceConfig{})
+	fake.sendErr = func(text string) error {
+		if len(text) > maxMessageLength/2 {
+			return tooLongError
+		}
+		return nil
+	}
+
+	text := strings.Repeat("word ", maxMessageLength/5)
+	if err := sendChunks(context.Background(), ws, 1, text); err != nil {
+		t.Fatal(err)
+	}
+
+	got := fake.texts()
+	if len(got) < 2 {
+		t.Fatalf("sent %d messages, want the answer split in two or more", len(got))
+	}
+	if joined := strings.Join(got, " "); strings.Count(joined, "word") != maxMessageLength/5 {
+		t.Errorf("split messages hold %d words, want %d", strings.Count(joined, "word"), maxMessageLength/5)
+	}
+}
+
+// checkChunks fails t unless every chunk fits size UTF-16 code units and
+// closes all code blocks it opens
+func checkChunks(t *testing.T, chunks []string, size int) {
+	t.Helper()
+	for i, chunk := range chunks {
+		if n := utf16Len([]rune(chunk)); n > size {
+			t.Errorf("chunk %d is %d UTF-16 code units, over %d", i, n, size)
+		}
+		if _, open := openCodeFence(chunk); open {
+			t.Errorf("chunk %d leaves a code block open: %q", i, truncateText(chunk, 80))
+		}
+	}
+}
+
+func TestChunkStringCodeBlock(t *testing.T) {
+	code := strings.Repeat("fmt.Println(i)\n", 400) // 6000 bytes
+	text := "Here is the code:\n\n```go\n" + code + "```\n\nDone."
+
+	chunks := chunkString(text, maxMessageLength)
+	checkChunks(t, chunks, maxMessageLength)
+
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(chunks))
+	}
+	if !strings.HasSuffix(chunks[0], "\n```") {
+		t.Errorf("first chunk doesn't close the code block: %q", chunks[0][len(chunks[0])-20:])
+	}
+	if !strings.HasPrefix(chunks[1], "```go\n") {
+		t.Errorf("second chunk doesn't reopen the code block: %q", chunks[1][:20])
+	}
+	if got := strings.Count(strings.Join(chunks, ""), "fmt.Println(i)"); got != 400 {
+		t.Errorf("chunks hold %d lines of code, want 400", got)
+	}
+}
+
+// serveFiles makes downloads go to a test server answering every file path
+// with its own name
+func serveFiles(t *testing.T) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, path.Base(r.URL.Path))
+	}))
+	t.Cleanup(srv.Close)
+
+	old := fileURLFormat
+	fileURLFormat = srv.URL + "/file/bot%s/%s"
+	t.Cleanup(func() { fileURLFormat = old })
+}
+
+func TestDownloadToTempConcurrent(t *testing.T) {
+	serveFiles(t)
+	ws, _ := newTestWorkspace(t, config.WorkspaceConfig{})
+
+	// Downloads of one chat started in the same second used to share a path
+	const n = 10
+	paths := make([]string, n)
+	var wg sync.WaitGroup
+	for i := range paths {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p, err := downloadToTemp(context.Background(), ws, fmt.Sprintf("photos/file_%d.jpg", i), "photo_1_*.jpg")
+			if err != nil {
+				t.Error(err)
+			}
+			paths[i] = p
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, p := range paths {
+		if seen[p] {
+			t.Errorf("download %d reused path %s", i, p)
+		}
+		seen[p] = true
+
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Errorf("download %d: %v", i, err)
+			continue
+		}
+		if want := fmt.Sprintf("file_%d.jpg", i); string(data) != want {
+			t.Errorf("download %d holds %q, want %q", i, data, want)
+		}
+	}
+}
+
+func TestChunkStringBoundaries(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []string
+	}{
+		{
+			name: "fits",
+			text: "short answer",
+			size: 40,
+			want: []string{"short answer"},
+		},
+		{
+			name: "paragraph before line break",
+			text: "the first paragraph is here\n\nsecond one\nwith two lines and more words",
+			size: 50,
+			want: []string{"the first paragraph is here", "second one\nwith two lines and more words"},
+		},
+		{
+			name: "line break before space",
+			text: "the line one has words\nline two has more words in it",
+			size: 40,
+			want: []string{"the line one has words", "line two has more words in it"},
+		},
+		{
+			name: "space",
+			text: "words without any line breaks at all here",
+			size: 30,
+			want: []string{"words without any line", "breaks at all here"},
+		},
+		{
+			name: "hard cut",
+			text: strings.Repeat("x", 50),
+			size: 30,
+			want: []string{strings.Repeat("x", 26), strings.Repeat("x", 24)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chunkString(tt.text, tt.size)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("chunkString = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChunkStringUTF16(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		// 3000 runes fit a rune limit of 4096, but are 6000 UTF-16 code units
+		{name: "emoji", text: strings.Repeat("😀", 3000)},
+		{name: "emoji words", text: strings.Repeat("🚀🔥 ", 1500)},
+		{name: "mixed", text: strings.Repeat("ok 👍 한글 ", 800)},
+		{name: "emoji code block", text: "```\n" + strings.Repeat("🎉\n", 2500) + "```"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkString(tt.text, maxMessageLength)
+			checkChunks(t, chunks, maxMessageLength)
+			if len(chunks) < 2 {
+				t.Errorf("got %d chunk for %d UTF-16 code units", len(chunks), utf16Len([]rune(tt.text)))
+			}
+			for i, chunk := range chunks {
+				if !utf8.ValidString(chunk) {
+					t.Errorf("chunk %d splits a character", i)
+				}
+			}
+			if got, want := strings.Count(strings.Join(chunks, ""), "😀"), strings.Count(tt.text, "😀"); got != want {
+				t.Errorf("chunks hold %d emoji, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestHandlePhotoMessageWithoutPhoto(t *testing.T) {
+	tests := []struct {
+		name  string
+		photo []telego.PhotoSize
+	}{
+		{name: "nil"},
+		{name: "empty", photo: []telego.PhotoSize{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, fake := newTestWorkspace(t, config.WorkspaceConfig{})
+			msg := &telego.Message{Chat: telego.Chat{ID: 1}, Photo: tt.photo, Caption: "what is this?"}
+
+			if err := newTestManager(t).handlePhotoMessage(context.Background(), ws, msg); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := fake.texts(); len(got) != 1 || got[0] != "No image found in message\\." {
+				t.Errorf("replies = %q, want the no image message", got)
+			}
+		})
+	}
+}
+
+func TestHandleStatusUnderscores(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my_project", "src_main")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ws, fake := newTestWorkspace(t, config.WorkspaceConfig{Name: "team_bot", WorkingDir: dir})
+
+	if err := (&Manager{}).handleStatus(context.Background(), ws, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Inside code spans underscores are literal and must not be escaped
+	text := fake.texts()[0]
+	for _, want := range []string{"\\- Workspace: `team_bot`\n", "\\- Working Dir: `" + dir + "`\n"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("status %q does not contain %q", text, want)
+		}
+	}
+}
+
+func TestChunkStringReopensLanguage(t *testing.T) {
+	text := "Python:\n```python\n" + strings.Repeat("print(i)\n", 12) + "```\n" +
+		"Go:\n```go\n" + strings.Repeat("fmt.Println(i)\n", 8) + "```\n" +
+		"Shell:\n```bash\n" + strings.Repeat("echo $i\n", 12) + "```"
+
+	chunks := chunkString(text, 60)
+	checkChunks(t, chunks, 60)
+
+	langs := map[string]string{"print(i)": "python", "fmt.Println(i)": "go", "echo $i": "bash"}
+	reopened := 0
+	for i, chunk := range chunks {
+		lines := strings.Split(chunk, "\n")
+		if !strings.HasPrefix(lines[0], codeFence) {
+			continue
+		}
+		reopened++
+		if want := langs[lines[1]]; lines[0] != codeFence+want {
+			t.Errorf("chunk %d reopens with %q, want %q: %q", i, lines[0], codeFence+want, chunk)
+		}
+	}
+	if reopened < 3 {
+		t.Errorf("%d chunks reopen a code block, want one or more per block", reopened)
+	}
+	for line, lang := range langs {
+		if got, want := strings.Count(strings.Join(chunks, "\n"), line), strings.Count(text, line); got != want {
+			t.Errorf("chunks hold %d lines of %s, want %d", got, lang, want)
+		}
+	}
+}
+
+func TestCallbackQueryDisabledCommand(t *testing.T) {
+	installFakeCLI(t, "opencode")
+	tests := []struct {
+		name     string
+		disabled []string
+		data     string
+		acted    bool
+	}{
+		{name: "cli", data: callbackCLIPrefix + "opencode", acted: true},
+		{name: "cli disabled", disabled: []string{"/cli"}, data: callbackCLIPrefix + "opencode"},
+		{name: "reset", data: callbackResetConfirm, acted: true},
+		{name: "reset with /clear", disabled: []string{"/new"}, data: callbackResetConfirm, acted: true},
+		{name: "reset disabled", disabled: []string{"/new", "/clear"}, data: callbackResetConfirm},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, fake := newTestWorkspace(t, config.WorkspaceConfig{
+				AllowedChats:     []int64{1},
+				DisabledCommands: tt.disabled,
+			})
+			key := sessionKey{chatID: 1}
+			ws.Bot.sessionMgr.Set(key, "first")
+
+			query := &telego.CallbackQuery{
+				ID:      "q",
+				From:    telego.User{ID: 1},
+				Message: &telego.Message{MessageID: 7, Chat: telego.Chat{ID: 1}},
+				Data:    tt.data,
+			}
+			if err := (&Manager{}).handleCallbackQuery(context.Background(), ws, query); err != nil {
+				t.Fatal(err)
+			}
+
+			acted := ws.Bot.GetCLI(key) != "claude" || ws.Bot.GetSessionID(key) == ""
+			if acted != tt.acted {
+				t.Errorf("acted = %v, want %v", acted, tt.acted)
+			}
+			if len(fake.answers) != 1 {
+				t.Fatalf("answered %d times, want once", len(fake.answers))
+			}
+			if text := fake.answers[0].Text; (text == ws.Bot.t(key, "command.disabled")) == tt.acted {
+				t.Errorf("answer = %q with the command acted on = %v", text, tt.acted)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// readyTimeout bounds the GetMe calls made by /readyz
+const readyTimeout = 5 * time.Second
+
+// startHealthServer serves /healthz and /readyz on addr
+func (m *Manager) startHealthServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", m.handleHealthz)
+	mux.HandleFunc("/readyz", m.handleReadyz)
+
+	m.healthServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	fmt.Printf("🩺 Health checks listening on %s\n", addr)
+	go func() {
+		if err := m.healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("❌ Health server error: %v\n", err)
+		}
+	}()
+}
+
+// stopHealthServer shuts the health server down, if it was started
+func (m *Manager) stopHealthServer() {
+	if m.healthServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.healthServer.Shutdown(ctx); err != nil {
+		fmt.Printf("⚠️ Failed to stop health server: %v\n", err)
+	}
+}
+
+// handleHealthz reports whether every bot is still receiving updates
+func (m *Manager) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	var stopped []string
+	for _, group := range m.groups {
+		if !group.receiving.Load() {
+			stopped = append(stopped, strings.Join(group.names(), ","))
+		}
+	}
+
+	if len(stopped) > 0 {
+		http.Error(w, "not receiving updates: "+strings.Join(stopped, " "), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz reports whether every bot can reach the Telegram API
+func (m *Manager) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	var failures []string
+	for _, group := range m.groups {
+		if _, err := group.TgBot.GetMe(ctx); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", strings.Join(group.names(), ","), err))
+		}
+	}
+
+	if len(failures) > 0 {
+		http.Error(w, strings.Join(failures, "\n"), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
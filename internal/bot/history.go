@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"strings"
+	"time"
+)
+
+// maxLastOutputBytes bounds the response kept per chat for /resend
+const maxLastOutputBytes = 256 * 1024
+
+// History entries only keep as much of an exchange as /history shows, in runes
+const (
+	maxHistoryPromptLength   = 200
+	maxHistoryResponseLength = 300
+)
+
+// Exchange is a prompt sent to the CLI and the response it produced
+type Exchange struct {
+	Prompt   string
+	Response string
+	Time     time.Time
+}
+
+// lastPrompt is the latest prompt of a chat, kept for /retry
+type lastPrompt struct {
+	Prompt string
+	Files  []attachment // Temp paths are not kept, the files are deleted
+}
+
+// historyRing is a fixed-size ring buffer of the latest exchanges of a chat
+type historyRing struct {
+	entries []Exchange
+	next    int
+	full    bool
+}
+
+// add stores an exchange, overwriting the oldest one when the ring is full
+func (r *historyRing) add(e Exchange) {
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// last returns up to n exchanges, oldest first
+func (r *historyRing) last(n int) []Exchange {
+	count := r.next
+	if r.full {
+		count = len(r.entries)
+	}
+	if n > count {
+		n = count
+	}
+
+	result := make([]Exchange, 0, n)
+	for i := n; i > 0; i-- {
+		idx := (r.next - i + len(r.entries)) % len(r.entries)
+		result = append(result, r.entries[idx])
+	}
+	return result
+}
+
+// RecordExchange adds a prompt and its response, shortened to what /history
+// shows, to the chat history. The response is also kept, even with the
+// history disabled, for /resend.
+func (b *Bot) RecordExchange(key sessionKey, prompt, response string) {
+	b.historyMu.Lock()
+	defer b.historyMu.Unlock()
+
+	output := response
+	if len(output) > maxLastOutputBytes {
+		output = strings.ToValidUTF8(output[:maxLastOutputBytes], "") + "\n… (truncated)"
+	}
+	b.lastOutputs[key] = output
+
+	if b.historySize <= 0 {
+		return
+	}
+	ring := b.history[key]
+	if ring == nil {
+		ring = &historyRing{entries: make([]Exchange, b.historySize)}
+		b.history[key] = ring
+	}
+	ring.add(Exchange{
+		Prompt:   truncateText(prompt, maxHistoryPromptLength),
+		Response: truncateText(response, maxHistoryResponseLength),
+		Time:     time.Now(),
+	})
+}
+
+// GetHistory returns up to the n most recent exchanges of a chat, oldest first
+func (b *Bot) GetHistory(key sessionKey, n int) []Exchange {
+	b.historyMu.Lock()
+	defer b.historyMu.Unlock()
+	ring := b.history[key]
+	if ring == nil {
+		return nil
+	}
+	return ring.last(n)
+}
+
+// GetLastOutput returns the latest response of a chat, or false if there is none
+func (b *Bot) GetLastOutput(key sessionKey) (string, bool) {
+	b.historyMu.Lock()
+	defer b.historyMu.Unlock()
+	output, ok := b.lastOutputs[key]
+	return output, ok
+}
+
+// SetLastPrompt remembers the latest prompt of a chat for /retry
+func (b *Bot) SetLastPrompt(key sessionKey, prompt string, files []attachment) {
+	last := lastPrompt{Prompt: prompt, Files: make([]attachment, len(files))}
+	for i, file := range files {
+		file.Path = ""
+		last.Files[i] = file
+	}
+
+	b.historyMu.Lock()
+	defer b.historyMu.Unlock()
+	b.lastPrompts[key] = last
+}
+
+// GetLastPrompt returns the latest prompt of a chat, or false if there is none
+func (b *Bot) GetLastPrompt(key sessionKey) (lastPrompt, bool) {
+	b.historyMu.Lock()
+	defer b.historyMu.Unlock()
+	last, ok := b.lastPrompts[key]
+	return last, ok
+}
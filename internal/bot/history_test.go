@@ -0,0 +1,31 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"telecode/internal/config"
+)
+
+func TestRecordExchangeTruncates(t *testing.T) {
+	ws, _ := newTestWorkspace(t, config.WorkspaceConfig{HistorySize: 2})
+	key := sessionKey{chatID: 1}
+	response := strings.Repeat("output line\n", 100000) // 1.2MB
+
+	ws.Bot.RecordExchange(key, strings.Repeat("p", 1000), response)
+
+	history := ws.Bot.GetHistory(key, 1)
+	if len(history) != 1 {
+		t.Fatalf("got %d exchanges, want 1", len(history))
+	}
+	if n := utf8.RuneCountInString(history[0].Prompt); n != maxHistoryPromptLength {
+		t.Errorf("prompt kept %d runes, want %d", n, maxHistoryPromptLength)
+	}
+	if n := utf8.RuneCountInString(history[0].Response); n != maxHistoryResponseLength {
+		t.Errorf("response kept %d runes, want %d", n, maxHistoryResponseLength)
+	}
+	if output, _ := ws.Bot.GetLastOutput(key); len(output) > maxLastOutputBytes+len("\n… (truncated)") {
+		t.Errorf("kept %d bytes for /resend, want at most %d", len(output), maxLastOutputBytes)
+	}
+}
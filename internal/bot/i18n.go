@@ -0,0 +1,499 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultLang is used for conversations without a language and for messages
+// missing from a translation
+const defaultLang = "en"
+
+// messages holds the bot's replies by language and message ID. Values are
+// plain text fmt formats; callers escape them for MarkdownV2.
+var messages = map[string]map[string]string{
+	"en": {
+		"lang.current":      "🌐 Language: %s. Available: %s. Use /lang <code>",
+		"lang.changed":      "🌐 Language changed to %s",
+		"lang.unknown":      "❌ Unknown language '%s'. Available: %s",
+		"auth.denied":       "⛔ Not authorized",
+		"rate.limited":      "🚧 Rate limit reached, try again in %.0fs",
+		"reset.confirm":     "⚠️ Reset the current session? Its context will be lost.",
+		"reset.canceled":    "Reset canceled, the session is kept.",
+		"reset.done":        "Session reset",
+		"session.new":       "✅ New session started!",
+		"session.new_hint":  "You can now send your message.",
+		"session.restored":  "↩️ Restored previous session",
+		"session.no_undo":   "Nothing to undo.",
+		"session.none":      "No sessions recorded yet.",
+		"session.lost":      "ℹ️ (new session — previous context may be lost)",
+		"session.expired":   "🕑 Session expired, starting fresh",
+		"retry.none":        "Nothing to retry.",
+		"resend.none":       "No previous output.",
+		"command.disabled":  "🚫 This command is disabled.",
+		"cancel.none":       "Nothing to cancel.",
+		"cancel.running":    "🛑 Canceling current command...",
+		"stop.done":         "🛑 Session stopped and cleared.",
+		"history.none":      "No history yet.",
+		"prompt.too_long":   "❌ Prompt is too long (%d bytes, max %d). Send large text as a document instead.",
+		"prompt.queued":     "⏳ Previous command still running, queued.",
+		"prompt.queue_full": "❌ Too many queued commands, please wait for the current ones to finish",
+		"prompt.no_command": "❌ Failed to build command",
+		"prompt.cached":     "♻️ Cached answer from %s ago, use /nocache <prompt> to run it again",
+		"prompt.wait_slot":  "⌛ Waiting for a free slot.",
+
+		"resume.usage":   "Usage: /resume <session id>",
+		"resume.done":    "✅ Resumed session",
+		"sessions.title": "Sessions",
+		"sessions.hint":  "Switch with /resume <id>",
+
+		"retry.attachment_gone": "❌ The attachment of the last prompt is no longer available, please send it again",
+
+		"nocache.usage": "Usage: /nocache <prompt>",
+
+		"persona.none":    "No system prompt set for this workspace.",
+		"persona.current": "🎭 System prompt:",
+
+		"whoami.title": "Who am I",
+		"whoami.body":  "- User ID: %s\n- Chat ID: %s\n- Authorized: %s",
+		"whoami.yes":   "✅ yes",
+		"whoami.no":    "❌ no",
+
+		"ping": "🏓 pong (uptime: %s)",
+
+		"env.admins_only": "⛔ /env is restricted to admins",
+		"env.title":       "Effective configuration",
+
+		"status.title":  "Current Status",
+		"status.body":   "- Workspace: %s\n- Working Dir: %s\n- CLI: %s\n- Session: %s\n- State: %s",
+		"state.idle":    "idle",
+		"state.queued":  "queued (%d ahead)",
+		"state.running": "running (%s)",
+		"state.waiting": ", %d queued",
+
+		"cli.current":     "📋 Current CLI: %s",
+		"cli.unsupported": "❌ Unsupported CLI. Use: %s",
+		"cli.changed":     "✅ CLI changed to: %s (session reset)",
+
+		"cd.current": "📁 Working directory: %s",
+		"cd.changed": "✅ Working directory changed to: %s (session reset)",
+
+		"model.cli_default": "CLI default",
+		"model.current":     "🧠 Current model: %s",
+		"model.available":   "Available: %s",
+		"model.not_allowed": "❌ %v. Allowed models: %s",
+		"model.changed":     "✅ Model changed to: %s",
+
+		"collect.state": "📥 Collect mode is %s. Use /collect on|off",
+		"collect.on":    "📥 Collect mode on: messages sent within %s of each other form one prompt",
+		"collect.off":   "📥 Collect mode off",
+		"collect.usage": "Usage: /collect on|off",
+
+		"verbose.state": "🔍 Verbose mode is %s. Use /verbose on|off",
+		"verbose.on":    "🔍 Verbose mode on: answers are followed by the command, its duration and exit code",
+		"verbose.off":   "🔍 Verbose mode off",
+		"verbose.usage": "Usage: /verbose on|off",
+
+		"dryrun.state": "🧪 Dry-run mode is %s. Use /dryrun on|off",
+		"dryrun.on":    "🧪 Dry-run mode on: prompts show the command they would run instead of running it",
+		"dryrun.off":   "🧪 Dry-run mode off",
+		"dryrun.usage": "Usage: /dryrun on|off",
+
+		"stats.usage":             "Usage: /stats [reset]",
+		"stats.reset":             "📊 Statistics reset.",
+		"stats.title":             "Statistics",
+		"stats.chat":              "This chat since %s: %d prompt(s), %d failed, %s CLI time",
+		"stats.usage_unavailable": "Tokens: usage unavailable",
+		"stats.tokens":            "Tokens: %d input, %d output",
+		"stats.cost":              " (est. $%.4f)",
+
+		"history.usage": "❌ Usage: /history [count]",
+		"history.title": "🕘 Recent History",
+
+		"workspaces.title": "Workspaces",
+		"workspaces.hint":  "Switch with /workspace <name>",
+
+		"workspace.current":  "🗂 Current workspace: %s\nUsage: /workspace <name>",
+		"workspace.unknown":  "❌ Unknown workspace: %s\nAvailable: %s",
+		"workspace.denied":   "⛔ Not authorized for workspace %s",
+		"workspace.switched": "✅ Switched to workspace %s (dir: %s)",
+
+		"start.welcome": "👋 Welcome to telecode! Send a message and it runs as a prompt in this workspace.",
+
+		"help.title":  "Available Commands",
+		"help.footer": "Any other message is sent to the CLI as a prompt.",
+
+		"prompt.override_usage": "❌ Usage: %s%s <prompt>",
+		"prompt.canceled":       "🛑 Command canceled",
+
+		"reply.empty":       "(empty response)",
+		"reply.as_file":     "📎 Response is %d bytes, sent as response.txt",
+		"reply.truncated":   "… output truncated, %d more chunks omitted",
+		"reply.full_output": "📎 Full output",
+
+		"reset.yes":    "Yes",
+		"reset.cancel": "Cancel",
+
+		"media.image":             "image",
+		"media.file":              "file",
+		"media.video":             "video",
+		"media.voice":             "voice message",
+		"media.audio":             "audio file",
+		"media.info_failed":       "❌ Failed to get %s info",
+		"media.download_failed":   "❌ Failed to download %s",
+		"image.missing":           "No image found in message.",
+		"image.too_large":         "❌ Image too large.",
+		"document.too_large":      "❌ File too large (max %d KB)",
+		"document.not_text":       "❌ Only text files are supported",
+		"voice.not_configured":    "Voice transcription not configured.",
+		"voice.transcribe_failed": "❌ Failed to transcribe %s",
+		"voice.no_speech":         "❌ No speech recognized in %s",
+		"audio.too_large":         "❌ Audio file too large (max %d KB)",
+		"audio.too_long":          "❌ Audio file too long (max %s)",
+
+		"video.not_configured": "Video messages not configured, set ffmpeg_binary.",
+		"video.too_large":      "❌ Video too large (max %d KB)",
+		"video.too_long":       "❌ Video too long (max %s)",
+		"video.frame_failed":   "❌ Failed to extract a frame from the video",
+
+		"compare.usage":       "❌ Usage: /compare <prompt>",
+		"compare.not_allowed": "❌ /compare needs %s, which this workspace doesn't allow",
+		"compare.started":     "⚖️ Comparing %s…",
+		"compare.and":         " and ",
+
+		"bg.usage":      "Usage: /bg <prompt>",
+		"bg.started":    "🧵 Task #%d started, I'll message you when done",
+		"bg.finished":   "%s Task #%d finished: %s",
+		"tasks.none":    "No background tasks. Start one with /bg <prompt>",
+		"tasks.title":   "🧵 Background Tasks",
+		"tasks.running": "running",
+		"tasks.done":    "done",
+		"tasks.failed":  "failed",
+
+		"inline.rate_limited": "🚧 Rate limit reached, try again later",
+
+		"context.not_found": "❌ Directory not found",
+		"context.outside":   "❌ Only the working directory and its subdirectories can be shown",
+		"context.not_dir":   "❌ Not a directory",
+		"context.empty":     "📂 %s is empty",
+		"context.header":    "📂 %s (%d files)",
+
+		"files.changed":     "📝 Changed files",
+		"files.recent":      "📝 Recently changed files",
+		"files.more":        "… and %d more",
+		"files.size":        "(%d bytes)",
+		"files.hint":        "Download one with /files <path>",
+		"files.none":        "No files changed in the last %s.",
+		"files.list_failed": "❌ Failed to list files: %v",
+		"files.not_found":   "❌ File not found",
+		"files.outside":     "❌ Only files in the working directory can be downloaded",
+		"files.not_regular": "❌ Not a regular file",
+		"files.too_large":   "❌ File is too large (%d bytes, max %d)",
+		"files.read_failed": "❌ Failed to read file: %v",
+
+		"git.not_repo":   "❌ %s is not a git repository",
+		"git.failed":     "❌ git %s failed: %v",
+		"diff.usage":     "Usage: /diff [--stat]",
+		"diff.none":      "✅ No uncommitted changes (untracked files aren't shown)",
+		"diff.as_file":   "📎 Diff is %d bytes",
+		"commit.usage":   "Usage: /commit <message>",
+		"commit.nothing": "Nothing to commit.",
+		"commit.no_hash": "❌ Committed, but reading the commit hash failed: %v",
+		"commit.done":    "✅ Committed %s",
+	},
+	"ko": {
+		"lang.current":      "🌐 언어: %s. 사용 가능: %s. /lang <코드>로 변경하세요",
+		"lang.changed":      "🌐 언어가 %s(으)로 변경되었습니다",
+		"lang.unknown":      "❌ 알 수 없는 언어 '%s'. 사용 가능: %s",
+		"auth.denied":       "⛔ 권한이 없습니다",
+		"rate.limited":      "🚧 요청 한도에 도달했습니다. %.0f초 후에 다시 시도하세요",
+		"reset.confirm":     "⚠️ 현재 세션을 초기화할까요? 대화 맥락이 사라집니다.",
+		"reset.canceled":    "초기화를 취소했습니다. 세션이 유지됩니다.",
+		"reset.done":        "세션 초기화됨",
+		"session.new":       "✅ 새 세션을 시작했습니다!",
+		"session.new_hint":  "이제 메시지를 보내세요.",
+		"session.restored":  "↩️ 이전 세션을 복원했습니다:",
+		"session.no_undo":   "되돌릴 세션이 없습니다.",
+		"session.none":      "기록된 세션이 없습니다.",
+		"session.lost":      "ℹ️ (새 세션 — 이전 맥락이 사라졌을 수 있습니다)",
+		"session.expired":   "🕑 세션이 만료되어 새로 시작합니다",
+		"retry.none":        "다시 보낼 프롬프트가 없습니다.",
+		"resend.none":       "이전 출력이 없습니다.",
+		"command.disabled":  "🚫 이 명령은 비활성화되어 있습니다.",
+		"cancel.none":       "취소할 명령이 없습니다.",
+		"cancel.running":    "🛑 실행 중인 명령을 취소하는 중...",
+		"stop.done":         "🛑 세션을 중지하고 초기화했습니다.",
+		"history.none":      "아직 기록이 없습니다.",
+		"prompt.too_long":   "❌ 프롬프트가 너무 깁니다 (%d바이트, 최대 %d). 긴 텍스트는 문서로 보내세요.",
+		"prompt.queued":     "⏳ 이전 명령이 실행 중이라 대기열에 추가했습니다.",
+		"prompt.queue_full": "❌ 대기 중인 명령이 너무 많습니다. 현재 명령이 끝날 때까지 기다려 주세요",
+		"prompt.no_command": "❌ 명령을 만들지 못했습니다",
+		"prompt.cached":     "♻️ %s 전의 캐시된 답변입니다. 다시 실행하려면 /nocache <프롬프트>를 사용하세요",
+		"prompt.wait_slot":  "⌛ 빈 실행 슬롯을 기다리는 중입니다.",
+
+		"resume.usage":   "사용법: /resume <세션 ID>",
+		"resume.done":    "✅ 세션을 재개했습니다:",
+		"sessions.title": "세션 목록",
+		"sessions.hint":  "/resume <ID>로 전환하세요",
+
+		"retry.attachment_gone": "❌ 마지막 프롬프트의 첨부 파일을 더 이상 사용할 수 없습니다. 다시 보내 주세요",
+
+		"nocache.usage": "사용법: /nocache <프롬프트>",
+
+		"persona.none":    "이 워크스페이스에는 시스템 프롬프트가 설정되어 있지 않습니다.",
+		"persona.current": "🎭 시스템 프롬프트:",
+
+		"whoami.title": "내 정보",
+		"whoami.body":  "- 사용자 ID: %s\n- 채팅 ID: %s\n- 권한: %s",
+		"whoami.yes":   "✅ 있음",
+		"whoami.no":    "❌ 없음",
+
+		"ping": "🏓 pong (가동 시간: %s)",
+
+		"env.admins_only": "⛔ /env는 관리자만 사용할 수 있습니다",
+		"env.title":       "적용된 설정",
+
+		"status.title":  "현재 상태",
+		"status.body":   "- 워크스페이스: %s\n- 작업 디렉터리: %s\n- CLI: %s\n- 세션: %s\n- 상태: %s",
+		"state.idle":    "대기 중",
+		"state.queued":  "대기열 (앞에 %d개)",
+		"state.running": "실행 중 (%s)",
+		"state.waiting": ", %d개 대기",
+
+		"cli.current":     "📋 현재 CLI: %s",
+		"cli.unsupported": "❌ 지원하지 않는 CLI입니다. 사용 가능: %s",
+		"cli.changed":     "✅ CLI를 %s(으)로 변경했습니다 (세션 초기화)",
+
+		"cd.current": "📁 작업 디렉터리: %s",
+		"cd.changed": "✅ 작업 디렉터리를 %s(으)로 변경했습니다 (세션 초기화)",
+
+		"model.cli_default": "CLI 기본값",
+		"model.current":     "🧠 현재 모델: %s",
+		"model.available":   "사용 가능: %s",
+		"model.not_allowed": "❌ %v. 허용된 모델: %s",
+		"model.changed":     "✅ 모델을 %s(으)로 변경했습니다",
+
+		"collect.state": "📥 모아 보내기 모드: %s. /collect on|off로 변경하세요",
+		"collect.on":    "📥 모아 보내기 모드 켜짐: %s 안에 연달아 보낸 메시지를 하나의 프롬프트로 합칩니다",
+		"collect.off":   "📥 모아 보내기 모드 꺼짐",
+		"collect.usage": "사용법: /collect on|off",
+
+		"verbose.state": "🔍 상세 모드: %s. /verbose on|off로 변경하세요",
+		"verbose.on":    "🔍 상세 모드 켜짐: 답변 뒤에 명령, 실행 시간, 종료 코드를 표시합니다",
+		"verbose.off":   "🔍 상세 모드 꺼짐",
+		"verbose.usage": "사용법: /verbose on|off",
+
+		"dryrun.state": "🧪 드라이런 모드: %s. /dryrun on|off로 변경하세요",
+		"dryrun.on":    "🧪 드라이런 모드 켜짐: 프롬프트를 실행하지 않고 실행할 명령만 보여 줍니다",
+		"dryrun.off":   "🧪 드라이런 모드 꺼짐",
+		"dryrun.usage": "사용법: /dryrun on|off",
+
+		"stats.usage":             "사용법: /stats [reset]",
+		"stats.reset":             "📊 통계를 초기화했습니다.",
+		"stats.title":             "통계",
+		"stats.chat":              "이 채팅 (%s부터): 프롬프트 %d개, 실패 %d개, CLI 시간 %s",
+		"stats.usage_unavailable": "토큰: 사용량 정보 없음",
+		"stats.tokens":            "토큰: 입력 %d, 출력 %d",
+		"stats.cost":              " (예상 $%.4f)",
+
+		"history.usage": "❌ 사용법: /history [개수]",
+		"history.title": "🕘 최근 기록",
+
+		"workspaces.title": "워크스페이스 목록",
+		"workspaces.hint":  "/workspace <이름>으로 전환하세요",
+
+		"workspace.current":  "🗂 현재 워크스페이스: %s\n사용법: /workspace <이름>",
+		"workspace.unknown":  "❌ 알 수 없는 워크스페이스: %s\n사용 가능: %s",
+		"workspace.denied":   "⛔ 워크스페이스 %s에 대한 권한이 없습니다",
+		"workspace.switched": "✅ 워크스페이스 %s(으)로 전환했습니다 (디렉터리: %s)",
+
+		"start.welcome": "👋 telecode에 오신 것을 환영합니다! 메시지를 보내면 이 워크스페이스에서 프롬프트로 실행됩니다.",
+
+		"help.title":  "사용 가능한 명령",
+		"help.footer": "그 밖의 메시지는 프롬프트로 CLI에 전달됩니다.",
+
+		"prompt.override_usage": "❌ 사용법: %s%s <프롬프트>",
+		"prompt.canceled":       "🛑 명령을 취소했습니다",
+
+		"reply.empty":       "(빈 응답)",
+		"reply.as_file":     "📎 응답이 %d바이트라 response.txt 파일로 보냈습니다",
+		"reply.truncated":   "… 출력이 잘렸습니다. %d개 메시지를 생략했습니다",
+		"reply.full_output": "📎 전체 출력",
+
+		"reset.yes":    "예",
+		"reset.cancel": "취소",
+
+		"media.image":             "이미지",
+		"media.file":              "파일",
+		"media.video":             "동영상",
+		"media.voice":             "음성 메시지",
+		"media.audio":             "오디오 파일",
+		"media.info_failed":       "❌ %s 정보를 가져오지 못했습니다",
+		"media.download_failed":   "❌ %s을(를) 내려받지 못했습니다",
+		"image.missing":           "메시지에 이미지가 없습니다.",
+		"image.too_large":         "❌ 이미지가 너무 큽니다.",
+		"document.too_large":      "❌ 파일이 너무 큽니다 (최대 %d KB)",
+		"document.not_text":       "❌ 텍스트 파일만 지원합니다",
+		"voice.not_configured":    "음성 변환이 설정되어 있지 않습니다.",
+		"voice.transcribe_failed": "❌ %s을(를) 텍스트로 변환하지 못했습니다",
+		"voice.no_speech":         "❌ %s에서 음성을 인식하지 못했습니다",
+		"audio.too_large":         "❌ 오디오 파일이 너무 큽니다 (최대 %d KB)",
+		"audio.too_long":          "❌ 오디오 파일이 너무 깁니다 (최대 %s)",
+
+		"video.not_configured": "동영상 메시지가 설정되어 있지 않습니다. ffmpeg_binary를 설정하세요.",
+		"video.too_large":      "❌ 동영상이 너무 큽니다 (최대 %d KB)",
+		"video.too_long":       "❌ 동영상이 너무 깁니다 (최대 %s)",
+		"video.frame_failed":   "❌ 동영상에서 프레임을 추출하지 못했습니다",
+
+		"compare.usage":       "❌ 사용법: /compare <프롬프트>",
+		"compare.not_allowed": "❌ /compare에는 %s이(가) 필요하지만 이 워크스페이스에서 허용되지 않습니다",
+		"compare.started":     "⚖️ %s 비교 중…",
+		"compare.and":         "와(과) ",
+
+		"bg.usage":      "사용법: /bg <프롬프트>",
+		"bg.started":    "🧵 작업 #%d을(를) 시작했습니다. 끝나면 알려 드릴게요",
+		"bg.finished":   "%s 작업 #%d 완료: %s",
+		"tasks.none":    "백그라운드 작업이 없습니다. /bg <프롬프트>로 시작하세요",
+		"tasks.title":   "🧵 백그라운드 작업",
+		"tasks.running": "실행 중",
+		"tasks.done":    "완료",
+		"tasks.failed":  "실패",
+
+		"inline.rate_limited": "🚧 요청 한도에 도달했습니다. 나중에 다시 시도하세요",
+
+		"context.not_found": "❌ 디렉터리를 찾을 수 없습니다",
+		"context.outside":   "❌ 작업 디렉터리와 그 하위 디렉터리만 볼 수 있습니다",
+		"context.not_dir":   "❌ 디렉터리가 아닙니다",
+		"context.empty":     "📂 %s이(가) 비어 있습니다",
+		"context.header":    "📂 %s (파일 %d개)",
+
+		"files.changed":     "📝 변경된 파일",
+		"files.recent":      "📝 최근 변경된 파일",
+		"files.more":        "… 외 %d개",
+		"files.size":        "(%d바이트)",
+		"files.hint":        "/files <경로>로 내려받으세요",
+		"files.none":        "최근 %s 동안 변경된 파일이 없습니다.",
+		"files.list_failed": "❌ 파일 목록을 가져오지 못했습니다: %v",
+		"files.not_found":   "❌ 파일을 찾을 수 없습니다",
+		"files.outside":     "❌ 작업 디렉터리 안의 파일만 내려받을 수 있습니다",
+		"files.not_regular": "❌ 일반 파일이 아닙니다",
+		"files.too_large":   "❌ 파일이 너무 큽니다 (%d바이트, 최대 %d)",
+		"files.read_failed": "❌ 파일을 읽지 못했습니다: %v",
+
+		"git.not_repo":   "❌ %s은(는) git 저장소가 아닙니다",
+		"git.failed":     "❌ git %s 실패: %v",
+		"diff.usage":     "사용법: /diff [--stat]",
+		"diff.none":      "✅ 커밋되지 않은 변경 사항이 없습니다 (추적되지 않는 파일은 표시되지 않습니다)",
+		"diff.as_file":   "📎 diff가 %d바이트입니다",
+		"commit.usage":   "사용법: /commit <메시지>",
+		"commit.nothing": "커밋할 변경 사항이 없습니다.",
+		"commit.no_hash": "❌ 커밋했지만 커밋 해시를 읽지 못했습니다: %v",
+		"commit.done":    "✅ 커밋했습니다: %s",
+
+		"help/new":        "기본 CLI와 모델로 새 세션을 시작합니다",
+		"help/clear":      "/new와 같습니다",
+		"help/undo":       "이전 세션을 복원합니다",
+		"help/sessions":   "이 채팅에서 사용한 세션을 보여 줍니다",
+		"help/resume":     "이전 세션으로 전환합니다 (/resume <ID>)",
+		"help/status":     "현재 상태(워크스페이스, CLI, 세션)를 보여 줍니다",
+		"help/cli":        "CLI를 보여 주거나 전환합니다 (claude | opencode | aider | gemini)",
+		"help/cd":         "작업 디렉터리를 보여 주거나 변경합니다 (/cd <경로>)",
+		"help/context":    "작업 디렉터리의 파일 트리를 보여 줍니다 (하위 디렉터리는 /context <경로>)",
+		"help/diff":       "작업 디렉터리의 커밋되지 않은 변경 사항을 보여 줍니다 (요약은 /diff --stat)",
+		"help/commit":     "작업 디렉터리의 모든 변경 사항을 커밋합니다 (/commit <메시지>, allow_git 필요)",
+		"help/files":      "최근 변경된 파일을 보여 주거나 내려받습니다 (/files <경로>)",
+		"help/model":      "모델을 보여 주거나 전환합니다 (/model default로 기본값 복원)",
+		"help/persona":    "워크스페이스의 시스템 프롬프트를 보여 줍니다",
+		"help/stats":      "채팅과 CLI 통계를 보여 줍니다 (/stats reset으로 채팅 통계 초기화)",
+		"help/collect":    "빠르게 보낸 메시지를 하나의 프롬프트로 합칩니다 (/collect on|off)",
+		"help/lang":       "봇 메시지의 언어를 보여 주거나 설정합니다 (/lang en|ko)",
+		"help/verbose":    "답변 뒤에 명령, 실행 시간, 종료 코드를 보여 줍니다 (/verbose on|off)",
+		"help/dryrun":     "프롬프트를 실행하지 않고 실행할 명령을 보여 줍니다 (/dryrun on|off)",
+		"help/retry":      "마지막 프롬프트를 다시 보냅니다",
+		"help/nocache":    "캐시된 답변 없이 프롬프트를 실행합니다 (/nocache <프롬프트>)",
+		"help/cancel":     "실행 중인 명령과 백그라운드 작업을 취소합니다",
+		"help/stop":       "모든 명령을 취소하고 세션을 초기화합니다",
+		"help/bg":         "프롬프트를 백그라운드에서 실행하고 끝나면 답변을 받습니다 (/bg <프롬프트>)",
+		"help/tasks":      "이 채팅의 백그라운드 작업을 보여 줍니다",
+		"help/compare":    "claude와 opencode로 프롬프트를 실행해 두 답변을 보여 줍니다 (/compare <프롬프트>, allow_compare 필요)",
+		"help/resend":     "마지막 응답을 다시 보냅니다",
+		"help/history":    "최근 프롬프트와 응답을 보여 줍니다 (/history [개수])",
+		"help/workspaces": "이 봇이 제공하는 워크스페이스를 보여 줍니다",
+		"help/workspace":  "이 채팅을 다른 워크스페이스로 전환합니다 (/workspace <이름>)",
+		"help/env":        "적용된 워크스페이스 설정을 보여 줍니다 (관리자 전용)",
+		"help/ping":       "봇이 응답하는지 확인합니다",
+		"help/whoami":     "내 사용자 ID와 이 채팅의 ID를 보여 줍니다",
+		"help/help":       "이 도움말을 보여 줍니다",
+	},
+}
+
+// supportedLangs returns the language codes there are messages for, sorted
+func supportedLangs() []string {
+	langs := make([]string, 0, len(messages))
+	for lang := range messages {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// messageFormat returns the format of message id in lang, falling back to
+// English for missing languages and messages
+func messageFormat(lang, id string) (string, bool) {
+	format, ok := messages[lang][id]
+	if !ok {
+		format, ok = messages[defaultLang][id]
+	}
+	return format, ok
+}
+
+// translate formats message id in lang, or returns id if there is no such
+// message
+func translate(lang, id string, args ...any) string {
+	format, ok := messageFormat(lang, id)
+	if !ok {
+		return id
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// t formats message id in the conversation's language
+func (b *Bot) t(key sessionKey, id string, args ...any) string {
+	return translate(b.GetLang(key), id, args...)
+}
+
+// tMarkdown formats message id in the conversation's language as MarkdownV2.
+// Only the format is escaped, args must already be MarkdownV2 such as mdCode.
+func (b *Bot) tMarkdown(key sessionKey, id string, args ...any) string {
+	format, ok := messageFormat(b.GetLang(key), id)
+	if !ok {
+		return escapeMarkdownV2(id)
+	}
+	return fmt.Sprintf(escapeMarkdownV2(format), args...)
+}
+
+// GetLang returns the language of a conversation's messages
+func (b *Bot) GetLang(key sessionKey) string {
+	b.settingsMu.RLock()
+	defer b.settingsMu.RUnlock()
+	if lang := b.chatSettings[key].Lang; lang != "" {
+		return lang
+	}
+	return defaultLang
+}
+
+// SetLang sets the language of a conversation's messages
+func (b *Bot) SetLang(key sessionKey, lang string) error {
+	if _, ok := messages[lang]; !ok {
+		return fmt.Errorf("unknown language '%s'", lang)
+	}
+
+	b.settingsMu.Lock()
+	settings := b.chatSettings[key]
+	settings.Lang = lang
+	b.chatSettings[key] = settings
+	b.settingsMu.Unlock()
+
+	b.persistSessions()
+	return nil
+}
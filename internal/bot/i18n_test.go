@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+// formatVerbs matches the fmt verbs of a message
+var formatVerbs = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestMessagesTranslated(t *testing.T) {
+	for lang, msgs := range messages {
+		if lang == defaultLang {
+			continue
+		}
+		for id, format := range messages[defaultLang] {
+			translated, ok := msgs[id]
+			if !ok {
+				t.Errorf("%s: message %q is not translated", lang, id)
+				continue
+			}
+			want := formatVerbs.FindAllString(format, -1)
+			if got := formatVerbs.FindAllString(translated, -1); !slices.Equal(got, want) {
+				t.Errorf("%s: message %q has verbs %v, want %v", lang, id, got, want)
+			}
+		}
+		for _, cmd := range commands {
+			if _, ok := msgs["help"+cmd.Name]; !ok {
+				t.Errorf("%s: the description of %s is not translated", lang, cmd.Name)
+			}
+		}
+	}
+}
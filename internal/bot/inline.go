@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+// inlineDebounce is how long an inline query has to stay unchanged before the
+// CLI runs. Telegram sends a new query for every edit while the user types.
+const inlineDebounce = 700 * time.Millisecond
+
+// startInlineRun registers the running inline query of a user, canceling the
+// one it supersedes. The returned function must be called once it is done.
+func (m *Manager) startInlineRun(ctx context.Context, userID int64) (context.Context, func()) {
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &runningCommand{cancel: cancel, started: time.Now()}
+
+	m.inlineMu.Lock()
+	if previous := m.inlineRuns[userID]; previous != nil {
+		previous.cancel()
+	}
+	m.inlineRuns[userID] = run
+	m.inlineMu.Unlock()
+
+	return runCtx, func() {
+		m.inlineMu.Lock()
+		if m.inlineRuns[userID] == run {
+			delete(m.inlineRuns, userID)
+		}
+		m.inlineMu.Unlock()
+		cancel()
+	}
+}
+
+// handleInlineQuery answers an inline query with the output of a one-off CLI
+// run, without any session
+func (m *Manager) handleInlineQuery(ctx context.Context, ws *WorkspaceBot, query *telego.InlineQuery) error {
+	prompt := strings.TrimSpace(query.Query)
+	if !ws.Config.InlineQueries || prompt == "" || !ws.Bot.IsInlineAllowed(query.From.ID) {
+		return nil
+	}
+
+	runCtx, done := m.startInlineRun(ctx, query.From.ID)
+	defer done()
+
+	select {
+	case <-time.After(inlineDebounce):
+	case <-runCtx.Done():
+		return nil // Superseded while typing
+	}
+
+	// Inline queries come from no chat, answer in the language of the user's own
+	key := sessionKey{chatID: query.From.ID}
+	if ok, _ := ws.Bot.AllowPrompt(query.From.ID); !ok {
+		return answerInline(ctx, ws, query.ID, prompt, ws.Bot.t(key, "inline.rate_limited"))
+	}
+
+	cli, cmd, stdin := ws.Bot.BuildOneOffCommand("", ws.Bot.OneOffModel(ws.Config.DefaultCLI), prompt, nil)
+	if cmd == nil {
+		return answerInline(ctx, ws, query.ID, prompt, ws.Bot.t(key, "prompt.no_command"))
+	}
+
+	releaseSlot, err := m.acquireSlot(runCtx, ws, nil)
+	if err != nil {
+		return nil
+	}
+	promptsTotal.WithLabelValues(ws.Config.Name, cli).Inc()
+	activeCommands.WithLabelValues(ws.Config.Name).Inc()
+	_, finishDirRun := ws.Bot.TrackRun(ws.Config.WorkingDir)
+	started := time.Now()
+	cmdCtx, cancelCmd := context.WithTimeout(runCtx, ws.Config.InlineTimeout)
+	result := runCommandWithDir(cmdCtx, cmd, ws.Config.WorkingDir, ws.Config.Env, stdin, nil)
+	cancelCmd()
+	duration := time.Since(started)
+	finishDirRun()
+	activeCommands.WithLabelValues(ws.Config.Name).Dec()
+	releaseSlot()
+	// Inline queries come from no chat, the user is logged instead
+	m.logCommand(ws, query.From.ID, cli, ws.Bot.OneOffModel(cli), prompt, result, duration)
+	observeCommand(ws.Config.Name, cli, result, duration)
+
+	if errors.Is(result.Err, context.Canceled) {
+		return nil // Superseded by a newer query
+	}
+
+	output := applyPostProcess(ctx, ws, cleanOutput(ws, formatOutput(ws.Bot.GetExecutor(cli), result.Stdout)))
+	result.Stderr = cleanOutput(ws, result.Stderr)
+	return answerInline(ctx, ws, query.ID, prompt, formatCommandResult(result, output, ws.Config.InlineTimeout))
+}
+
+// answerInline answers an inline query with a single article holding text
+func answerInline(ctx context.Context, ws *WorkspaceBot, queryID, prompt, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		text = "(empty response)"
+	}
+	// Inline results are a single message, longer answers are cut
+	if runes := []rune(text); utf16Len(runes) > maxMessageLength {
+		text = string(runes[:runesWithin(runes, maxMessageLength-1)]) + "…"
+	}
+
+	article := tu.ResultArticle("answer", truncateText(prompt, 64), tu.TextMessage(text)).
+		WithDescription(truncateText(text, 100))
+	return ws.TgBot.AnswerInlineQuery(ctx, tu.InlineQuery(queryID, article).
+		WithCacheTime(0).
+		WithIsPersonal())
+}
@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"telecode/internal/config"
+)
+
+func TestHandleInlineQueryLogsCommand(t *testing.T) {
+	installFakeCLIScript(t, "claude", `echo '{"type":"result","result":"42","session_id":"s"}'`+"\n")
+	ws, _ := newTestWorkspace(t, config.WorkspaceConfig{
+		Name:          "inline",
+		InlineQueries: true,
+		InlineTimeout: 10 * time.Second,
+		AllowedUsers:  []int64{7},
+	})
+	m := newTestManager(t)
+	m.slots[ws.Config.Name] = make(chan struct{}, 1)
+	var logs bytes.Buffer
+	m.logger = slog.New(slog.NewJSONHandler(&logs, nil))
+
+	query := &telego.InlineQuery{ID: "q", From: telego.User{ID: 7}, Query: "what is the answer?"}
+	if err := m.handleInlineQuery(context.Background(), ws, query); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{`"msg":"command executed"`, `"chat_id":7`, `"workspace":"inline"`, `"cli":"claude"`, `"exit_code":0`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("log %q does not contain %s", logs.String(), want)
+		}
+	}
+	if got := testutil.ToFloat64(activeCommands.WithLabelValues(ws.Config.Name)); got != 0 {
+		t.Errorf("active commands after the run = %v, want 0", got)
+	}
+}
@@ -3,108 +3,413 @@ package bot
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mymmrac/telego"
 	"telecode/internal/config"
 )
 
+// TelegramAPI is the part of the Telegram Bot API the handlers use. It is
+// implemented by *telego.Bot and lets handlers run against a fake API.
+type TelegramAPI interface {
+	SendMessage(ctx context.Context, params *telego.SendMessageParams) (*telego.Message, error)
+	EditMessageText(ctx context.Context, params *telego.EditMessageTextParams) (*telego.Message, error)
+	DeleteMessage(ctx context.Context, params *telego.DeleteMessageParams) error
+	SendDocument(ctx context.Context, params *telego.SendDocumentParams) (*telego.Message, error)
+	SendChatAction(ctx context.Context, params *telego.SendChatActionParams) error
+	GetFile(ctx context.Context, params *telego.GetFileParams) (*telego.File, error)
+	AnswerCallbackQuery(ctx context.Context, params *telego.AnswerCallbackQueryParams) error
+	AnswerInlineQuery(ctx context.Context, params *telego.AnswerInlineQueryParams) error
+}
+
 // WorkspaceBot represents a single workspace with its bot instance
 type WorkspaceBot struct {
 	Config config.WorkspaceConfig
 	Bot    *Bot
-	TgBot  *telego.Bot
+	TgBot  TelegramAPI
+	group  *botGroup
+}
+
+// botGroup holds the workspaces served by the same bot token. Updates are
+// polled once per group and routed to the workspace selected by each chat.
+type botGroup struct {
+	TgBot      *telego.Bot     // Also polls updates, which TelegramAPI leaves out
+	workspaces []*WorkspaceBot // In config order
+
+	mu       sync.RWMutex
+	selected map[int64]*WorkspaceBot
+
+	receiving atomic.Bool   // Whether the update loop is running
+	recent    recentUpdates // Update IDs seen lately, to drop redeliveries
+}
+
+// names returns the names of the workspaces in the group
+func (g *botGroup) names() []string {
+	names := make([]string, len(g.workspaces))
+	for i, ws := range g.workspaces {
+		names[i] = ws.Config.Name
+	}
+	return names
+}
+
+// find returns the workspace with the given name, or nil
+func (g *botGroup) find(name string) *WorkspaceBot {
+	for _, ws := range g.workspaces {
+		if ws.Config.Name == name {
+			return ws
+		}
+	}
+	return nil
+}
+
+// workspaceFor returns the workspace a chat is bound to. Chats without a
+// selection use the first workspace that allows them.
+func (g *botGroup) workspaceFor(chatID int64) *WorkspaceBot {
+	g.mu.RLock()
+	ws, ok := g.selected[chatID]
+	g.mu.RUnlock()
+	if ok {
+		return ws
+	}
+
+	for _, ws := range g.workspaces {
+		if ws.Bot.IsAllowed(chatID) {
+			return ws
+		}
+	}
+	return g.workspaces[0]
+}
+
+// selectWorkspace binds a chat to a workspace of the group
+func (g *botGroup) selectWorkspace(chatID int64, ws *WorkspaceBot) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.selected[chatID] = ws
 }
 
 // Manager handles multiple workspace bots
 type Manager struct {
 	workspaces map[string]*WorkspaceBot
+	groups     []*botGroup
+	startedAt  time.Time    // Reported as uptime by /ping
+	logger     *slog.Logger // Structured command execution logs
+	// healthServer serves health checks when HealthAddr is set
+	healthAddr   string
+	healthServer *http.Server
+	// metricsServer serves Prometheus metrics when MetricsAddr is set
+	metricsAddr   string
+	metricsServer *http.Server
+	// webhookServer receives updates in webhook mode
+	webhook       config.WebhookConfig
+	webhookServer *http.Server
+	mediaGroups   map[string]*mediaGroup
+	albums        map[string]albumAdmission // Rate limit verdicts of recent albums
+	mediaMu       sync.Mutex
+	// collects buffers messages of chats in collect mode
+	collects  map[string]*collectBuffer
+	collectMu sync.Mutex
+	// inlineRuns cancels the running inline query of each user
+	inlineRuns map[int64]*runningCommand
+	inlineMu   sync.Mutex
+	// slots limits concurrent CLI processes per workspace (token bucket)
+	slots map[string]chan struct{}
+	// handler runs updates through middlewares before dispatching them
+	middlewares []Middleware
+	handler     Handler
+
+	// Updates are polled with pollCtx, while handlers run with handlerCtx
+	// so in-flight commands can finish after polling stopped
+	pollCancel     context.CancelFunc
+	handlerCtx     context.Context
+	cancelHandlers context.CancelFunc
+	handlers       sync.WaitGroup
+	activeHandlers atomic.Int64
 }
 
 // NewManager creates a new multi-bot manager
 func NewManager(cfg *config.Config) (*Manager, error) {
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
 	mgr := &Manager{
-		workspaces: make(map[string]*WorkspaceBot),
+		workspaces:     make(map[string]*WorkspaceBot),
+		startedAt:      time.Now(),
+		mediaGroups:    make(map[string]*mediaGroup),
+		albums:         make(map[string]albumAdmission),
+		collects:       make(map[string]*collectBuffer),
+		inlineRuns:     make(map[int64]*runningCommand),
+		slots:          make(map[string]chan struct{}),
+		logger:         newLogger(cfg.LogFormat),
+		healthAddr:     cfg.HealthAddr,
+		metricsAddr:    cfg.MetricsAddr,
+		webhook:        cfg.Webhook,
+		pollCancel:     func() {},
+		handlerCtx:     handlerCtx,
+		cancelHandlers: cancelHandlers,
 	}
+	mgr.Use(mgr.defaultMiddlewares()...)
 
+	groups := make(map[string]*botGroup)
 	for _, wsConfig := range cfg.Workspaces {
-		// Convert allowed chats to map
-		allowedChats := make(map[int64]bool)
-		for _, chatID := range wsConfig.AllowedChats {
-			allowedChats[chatID] = true
+		// Commands can't run without their working directory
+		if info, err := os.Stat(wsConfig.WorkingDir); err != nil {
+			return nil, fmt.Errorf("workspace %s: working_dir %s is not accessible: %w", wsConfig.Name, wsConfig.WorkingDir, err)
+		} else if !info.IsDir() {
+			return nil, fmt.Errorf("workspace %s: working_dir %s is not a directory", wsConfig.Name, wsConfig.WorkingDir)
 		}
 
 		// Create bot logic instance
-		botLogic := NewBot(allowedChats, wsConfig.DefaultCLI, wsConfig.Model)
+		botLogic := NewBot(wsConfig)
+		if err := botLogic.LoadSessions(); err != nil {
+			return nil, fmt.Errorf("failed to load sessions for workspace %s: %w", wsConfig.Name, err)
+		}
 
-		// Create Telegram bot
-		var botOpts []telego.BotOption
-		tgBot, err := telego.NewBot(wsConfig.BotToken, botOpts...)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create bot for workspace %s: %w", wsConfig.Name, err)
+		// Create Telegram bot, shared by workspaces with the same token
+		group, ok := groups[wsConfig.BotToken]
+		if !ok {
+			var botOpts []telego.BotOption
+			tgBot, err := telego.NewBot(wsConfig.BotToken, botOpts...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create bot for workspace %s: %w", wsConfig.Name, err)
+			}
+			group = &botGroup{
+				TgBot:    tgBot,
+				selected: make(map[int64]*WorkspaceBot),
+			}
+			groups[wsConfig.BotToken] = group
+			mgr.groups = append(mgr.groups, group)
 		}
 
 		// Store workspace bot
-		mgr.workspaces[wsConfig.Name] = &WorkspaceBot{
+		ws := &WorkspaceBot{
 			Config: wsConfig,
 			Bot:    botLogic,
-			TgBot:  tgBot,
+			TgBot:  group.TgBot,
+			group:  group,
 		}
+		group.workspaces = append(group.workspaces, ws)
+		mgr.workspaces[wsConfig.Name] = ws
+		mgr.slots[wsConfig.Name] = make(chan struct{}, wsConfig.MaxConcurrent)
 	}
 
 	return mgr, nil
 }
 
-// Start starts all workspace bots
+// newLogger creates the logger for command executions in the given format
+func newLogger(format string) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	}
+	return slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
+// Start starts all workspace bots, receiving updates via long polling or
+// webhooks. Receiving stops when ctx is canceled or Shutdown is called.
 func (m *Manager) Start(ctx context.Context) error {
-	for name, ws := range m.workspaces {
-		fmt.Printf("🤖 Starting bot for workspace: %s (dir: %s)\n", name, ws.Config.WorkingDir)
+	ctx, m.pollCancel = context.WithCancel(ctx)
+
+	if m.healthAddr != "" {
+		m.startHealthServer(m.healthAddr)
+	}
+	if m.metricsAddr != "" {
+		m.startMetricsServer(m.metricsAddr)
+	}
+
+	for _, group := range m.groups {
+		for _, ws := range group.workspaces {
+			fmt.Printf("🤖 Starting bot for workspace: %s (dir: %s)\n", ws.Config.Name, ws.Config.WorkingDir)
+		}
+	}
+
+	if m.webhook.Mode == "webhook" {
+		return m.startWebhooks(ctx)
+	}
 
-		// Start this workspace's bot in a goroutine
-		go func(ws *WorkspaceBot) {
-			if err := m.runWorkspaceBot(ctx, ws); err != nil {
-				fmt.Printf("❌ Bot error for workspace %s: %v\n", ws.Config.Name, err)
+	for _, group := range m.groups {
+		// Start this token's bot in a goroutine
+		go func(group *botGroup) {
+			if err := m.runBotGroup(ctx, group); err != nil {
+				fmt.Printf("❌ Bot error for workspace %s: %v\n", strings.Join(group.names(), ", "), err)
 			}
-		}(ws)
+		}(group)
 	}
 
 	return nil
 }
 
-// runWorkspaceBot runs a single workspace bot
-func (m *Manager) runWorkspaceBot(ctx context.Context, ws *WorkspaceBot) error {
+// runBotGroup polls updates for a bot token and routes them to the
+// workspace selected by each chat
+func (m *Manager) runBotGroup(ctx context.Context, group *botGroup) error {
+	// Long polling doesn't work while a webhook is set
+	if err := group.TgBot.DeleteWebhook(ctx, nil); err != nil {
+		fmt.Printf("⚠️ Failed to remove webhook for workspace %s: %v\n", strings.Join(group.names(), ", "), err)
+	}
+
 	// Get updates
-	updates, err := ws.TgBot.UpdatesViaLongPolling(ctx, nil)
+	updates, err := group.TgBot.UpdatesViaLongPolling(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to start long polling: %w", err)
 	}
 
-	// Process updates
+	m.processUpdates(ctx, group, updates)
+	return nil
+}
+
+// processUpdates routes the updates of a bot token to the workspace selected
+// by each chat until ctx is canceled or updates is closed
+func (m *Manager) processUpdates(ctx context.Context, group *botGroup, updates <-chan telego.Update) {
+	group.receiving.Store(true)
+	defer group.receiving.Store(false)
+
 	for {
 		select {
 		case <-ctx.Done():
-			return nil
+			return
 		case update, ok := <-updates:
 			if !ok {
-				return nil
+				return
 			}
-			if err := m.handleUpdate(ctx, ws, update); err != nil {
-				fmt.Printf("❌ Error handling update for %s: %v\n", ws.Config.Name, err)
+			chatID, ok := updateChatID(update)
+			if !ok {
+				continue
 			}
+			ws := group.workspaceFor(chatID)
+			// Handle each update in its own goroutine so commands like
+			// /cancel are processed while a CLI invocation is running
+			done := m.trackHandler()
+			go func(update telego.Update) {
+				defer done()
+				if err := m.handleUpdate(m.handlerCtx, ws, update); err != nil {
+					fmt.Printf("❌ Error handling update for %s: %v\n", ws.Config.Name, err)
+				}
+			}(update)
 		}
 	}
 }
 
-// handleUpdate handles a single update for a workspace bot
+// acquireSlot waits for a free CLI slot in the workspace. onWait is called
+// if all slots are taken. The returned function releases the slot.
+func (m *Manager) acquireSlot(ctx context.Context, ws *WorkspaceBot, onWait func()) (func(), error) {
+	slots := m.slots[ws.Config.Name]
+	release := func() { <-slots }
+
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	if onWait != nil {
+		onWait()
+	}
+
+	select {
+	case slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// trackHandler registers an in-flight handler with the shutdown logic.
+// The returned function must be called once the handler is done.
+func (m *Manager) trackHandler() func() {
+	m.handlers.Add(1)
+	m.activeHandlers.Add(1)
+	return func() {
+		m.activeHandlers.Add(-1)
+		m.handlers.Done()
+	}
+}
+
+// Shutdown stops polling for updates, waits up to grace for in-flight
+// commands to finish, cancels the remaining ones and saves all sessions
+func (m *Manager) Shutdown(grace time.Duration) {
+	m.pollCancel()
+	m.stopWebhookServer()
+	m.stopHealthServer()
+	m.stopMetricsServer()
+
+	inFlight := m.activeHandlers.Load()
+	if inFlight > 0 {
+		fmt.Printf("⏳ Waiting up to %v for %d in-flight command(s)...\n", grace, inFlight)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(grace):
+	}
+
+	abandoned := m.activeHandlers.Load()
+	m.cancelHandlers()
+	if abandoned > 0 {
+		// Give canceled handlers a moment to clean up their temp files
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	for name, ws := range m.workspaces {
+		if err := ws.Bot.SaveSessions(); err != nil {
+			fmt.Printf("⚠️ Failed to save sessions for workspace %s: %v\n", name, err)
+		}
+	}
+
+	fmt.Printf("📦 Drained %d command(s), abandoned %d\n", inFlight-abandoned, abandoned)
+}
+
+// updateChatID returns the chat an update belongs to
+func updateChatID(update telego.Update) (int64, bool) {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID, true
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.GetChat().ID, true
+	case update.InlineQuery != nil:
+		// Inline queries have no chat, use the sender's private chat
+		return update.InlineQuery.From.ID, true
+	default:
+		return 0, false
+	}
+}
+
+// handleUpdate handles a single update for a workspace bot, passing it
+// through the middleware chain first
 func (m *Manager) handleUpdate(ctx context.Context, ws *WorkspaceBot, update telego.Update) error {
+	return m.handler(ctx, ws, update)
+}
+
+// dispatch routes an update that passed the middlewares to its handler
+func (m *Manager) dispatch(ctx context.Context, ws *WorkspaceBot, update telego.Update) error {
+	if update.CallbackQuery != nil {
+		return m.handleCallbackQuery(ctx, ws, update.CallbackQuery)
+	}
+	if update.InlineQuery != nil {
+		return m.handleInlineQuery(ctx, ws, update.InlineQuery)
+	}
 	if update.Message == nil {
 		return nil
 	}
 
 	chatID := update.Message.Chat.ID
+	key := chatKey(ctx, chatID)
+	userID := messageUserID(update.Message)
+	cmd := getCommandFromMessage(update.Message.Text)
 
-	// Check if chat is allowed
-	if !ws.Bot.IsAllowed(chatID) {
-		return nil
+	// Commands don't wait for the rest of a collected prompt
+	if strings.HasPrefix(update.Message.Text, "/") {
+		m.flushCollectedNow(ctx, ws, chatID)
 	}
 
 	// Check if message has photo
@@ -112,22 +417,162 @@ func (m *Manager) handleUpdate(ctx context.Context, ws *WorkspaceBot, update tel
 		return m.handlePhotoMessage(ctx, ws, update.Message)
 	}
 
-	// Get command handler
-	cmd := getCommandFromMessage(update.Message.Text)
+	// Check if message has a document
+	if update.Message.Document != nil {
+		return m.handleDocumentMessage(ctx, ws, update.Message)
+	}
+
+	// Check if message is a voice note
+	if update.Message.Voice != nil {
+		return m.handleVoiceMessage(ctx, ws, update.Message)
+	}
+
+	// Check if message is a video
+	if update.Message.Video != nil {
+		return m.handleVideoMessage(ctx, ws, update.Message)
+	}
+
+	// Check if message is an audio file
+	if update.Message.Audio != nil {
+		return m.handleAudioMessage(ctx, ws, update.Message)
+	}
 
 	switch cmd {
-	case "/new":
+	case "/new", "/clear":
 		return m.handleNewSession(ctx, ws, chatID)
+	case "/undo":
+		return m.handleUndo(ctx, ws, chatID)
+	case "/sessions":
+		return m.handleSessions(ctx, ws, chatID)
+	case "/resume":
+		return m.handleResume(ctx, ws, chatID, update.Message.Text)
+	case "/retry":
+		return m.handleRetry(ctx, ws, chatID)
+	case "/bg":
+		return m.handleBackground(ctx, ws, chatID, update.Message.Text)
+	case "/tasks":
+		return m.handleTasks(ctx, ws, chatID)
+	case "/compare":
+		return m.handleCompare(ctx, ws, chatID, update.Message.Text)
+	case "/resend":
+		return m.handleResend(ctx, ws, chatID)
+	case "/persona":
+		return m.handlePersona(ctx, ws, chatID)
 	case "/status":
 		return m.handleStatus(ctx, ws, chatID)
 	case "/cli":
 		return m.handleCLI(ctx, ws, chatID, update.Message.Text)
+	case "/cd":
+		return m.handleCd(ctx, ws, chatID, update.Message.Text)
+	case "/model":
+		return m.handleModel(ctx, ws, chatID, update.Message.Text)
 	case "/stats":
-		return m.handleStats(ctx, ws, chatID)
+		return m.handleStats(ctx, ws, chatID, update.Message.Text)
+	case "/cancel":
+		return m.handleCancel(ctx, ws, chatID)
+	case "/stop":
+		return m.handleStop(ctx, ws, chatID)
+	case "/history":
+		return m.handleHistory(ctx, ws, chatID, update.Message.Text)
+	case "/workspaces":
+		return m.handleWorkspaces(ctx, ws, chatID)
+	case "/workspace":
+		return m.handleWorkspace(ctx, ws, chatID, userID, update.Message.Text)
+	case "/env":
+		return m.handleEnv(ctx, ws, chatID, userID)
+	case "/ping":
+		return m.handlePing(ctx, ws, chatID)
+	case "/start":
+		return m.handleStart(ctx, ws, chatID)
+	case "/help":
+		return m.handleHelp(ctx, ws, chatID)
+	case "/collect":
+		return m.handleCollect(ctx, ws, chatID, update.Message.Text)
+	case "/lang":
+		return m.handleLang(ctx, ws, chatID, update.Message.Text)
+	case "/verbose":
+		return m.handleVerbose(ctx, ws, chatID, update.Message.Text)
+	case "/commit":
+		return m.handleCommit(ctx, ws, chatID, update.Message.Text)
+	case "/context":
+		return m.handleContext(ctx, ws, chatID, update.Message.Text)
+	case "/diff":
+		return m.handleDiff(ctx, ws, chatID, update.Message.Text)
+	case "/files":
+		return m.handleFiles(ctx, ws, chatID, update.Message.Text)
+	case "/nocache":
+		return m.handleNoCache(ctx, ws, chatID, update.Message.Text)
+	case "/dryrun":
+		return m.handleDryRun(ctx, ws, chatID, update.Message.Text)
 	default:
 		// Handle regular message
-		return m.handleMessage(ctx, ws, chatID, update.Message.Text, "")
+		prompt := withForwardContext(ws, update.Message, withReplyContext(ws, update.Message))
+		if ws.Bot.CollectEnabled(key) {
+			m.bufferPrompt(ctx, ws, chatID, prompt)
+			return nil
+		}
+		return m.handleMessage(ctx, ws, chatID, prompt, nil)
+	}
+}
+
+// withReplyContext returns the message's text as a prompt. With
+// reply_context enabled, the text of the message replied to is quoted first.
+func withReplyContext(ws *WorkspaceBot, message *telego.Message) string {
+	reply := message.ReplyToMessage
+	if !ws.Config.ReplyContext || reply == nil {
+		return message.Text
+	}
+	quoted := reply.Text
+	if quoted == "" {
+		quoted = reply.Caption
+	}
+	if strings.TrimSpace(quoted) == "" {
+		return message.Text
+	}
+	return "In reference to:\n" + quoted + "\n\n" + message.Text
+}
+
+// withForwardContext prefixes the prompt of a forwarded message with its
+// original sender when forward_context is enabled
+func withForwardContext(ws *WorkspaceBot, message *telego.Message, prompt string) string {
+	if !ws.Config.ForwardContext || message.ForwardOrigin == nil {
+		return prompt
+	}
+	sender := forwardSender(message.ForwardOrigin)
+	if sender == "" {
+		return prompt
+	}
+	return "Forwarded from " + sender + ":\n" + prompt
+}
+
+// forwardSender names the original sender of a forwarded message
+func forwardSender(origin telego.MessageOrigin) string {
+	var name, signature string
+	switch origin := origin.(type) {
+	case *telego.MessageOriginUser:
+		name = strings.TrimSpace(origin.SenderUser.FirstName + " " + origin.SenderUser.LastName)
+		if origin.SenderUser.Username != "" {
+			name += " (@" + origin.SenderUser.Username + ")"
+		}
+	case *telego.MessageOriginHiddenUser:
+		name = origin.SenderUserName
+	case *telego.MessageOriginChat:
+		name, signature = chatName(origin.SenderChat), origin.AuthorSignature
+	case *telego.MessageOriginChannel:
+		name, signature = chatName(origin.Chat), origin.AuthorSignature
+	}
+	if signature != "" {
+		name += " (" + signature + ")"
+	}
+	return name
+}
+
+// chatName returns the title of a chat, with its username if it has one
+func chatName(chat telego.Chat) string {
+	if chat.Username == "" {
+		return chat.Title
 	}
+	return strings.TrimSpace(chat.Title + " (@" + chat.Username + ")")
 }
 
 func getCommandFromMessage(text string) string {
@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+// mediaGroupWindow is how long to wait for further photos of an album
+const mediaGroupWindow = time.Second
+
+// mediaGroup collects the photos of an album sent as separate updates
+type mediaGroup struct {
+	ws       *WorkspaceBot
+	messages []*telego.Message
+	timer    *time.Timer
+	done     func()
+}
+
+// bufferMediaGroup adds a photo to its album. The album is processed once no
+// further photo arrived for mediaGroupWindow.
+func (m *Manager) bufferMediaGroup(ctx context.Context, ws *WorkspaceBot, message *telego.Message) {
+	key := ws.Config.Name + "/" + message.MediaGroupID
+
+	m.mediaMu.Lock()
+	defer m.mediaMu.Unlock()
+
+	group := m.mediaGroups[key]
+	if group == nil {
+		group = &mediaGroup{ws: ws, done: m.trackHandler()}
+		group.timer = time.AfterFunc(mediaGroupWindow, func() {
+			m.flushMediaGroup(ctx, key)
+		})
+		m.mediaGroups[key] = group
+	} else {
+		group.timer.Reset(mediaGroupWindow)
+	}
+	group.messages = append(group.messages, message)
+}
+
+// albumMemory is how long the rate limit verdict of an album is kept
+const albumMemory = time.Minute
+
+// albumAdmission is the rate limit verdict for the photos of an album
+type albumAdmission struct {
+	allowed bool
+	seen    time.Time
+}
+
+// admitAlbumPhoto decides whether a photo of an album may be processed. Only
+// the first photo is checked with allow and the others share its verdict;
+// first reports whether this was the photo checked. Photos of one album
+// arrive concurrently, so the album is marked as seen under the same lock.
+func (m *Manager) admitAlbumPhoto(ws *WorkspaceBot, mediaGroupID string, allow func() bool) (allowed, first bool) {
+	key := ws.Config.Name + "/" + mediaGroupID
+	now := time.Now()
+
+	m.mediaMu.Lock()
+	defer m.mediaMu.Unlock()
+
+	for k, admission := range m.albums {
+		if now.Sub(admission.seen) > albumMemory {
+			delete(m.albums, k)
+		}
+	}
+	if admission, ok := m.albums[key]; ok {
+		return admission.allowed, false
+	}
+
+	allowed = allow()
+	m.albums[key] = albumAdmission{allowed: allowed, seen: now}
+	return allowed, true
+}
+
+// flushMediaGroup processes a buffered album
+func (m *Manager) flushMediaGroup(ctx context.Context, key string) {
+	m.mediaMu.Lock()
+	group := m.mediaGroups[key]
+	delete(m.mediaGroups, key)
+	m.mediaMu.Unlock()
+
+	if group == nil {
+		return
+	}
+	defer group.done()
+
+	if err := m.handleMediaGroup(ctx, group.ws, group.messages); err != nil {
+		fmt.Printf("❌ Error handling album for %s: %v\n", group.ws.Config.Name, err)
+	}
+}
+
+// handleMediaGroup downloads all photos of an album and sends them to the
+// CLI with a single prompt
+func (m *Manager) handleMediaGroup(ctx context.Context, ws *WorkspaceBot, messages []*telego.Message) error {
+	// Updates may arrive out of order
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].MessageID < messages[j].MessageID
+	})
+
+	chatID := messages[0].Chat.ID
+
+	var photos []attachment
+	defer func() {
+		for _, photo := range photos {
+			os.Remove(photo.Path) // Clean up temp files
+		}
+	}()
+
+	// The caption is usually only set on one item of the album
+	prompt := ""
+	for _, message := range messages {
+		photo, err := downloadPhoto(ctx, ws, message)
+		if errors.Is(err, errImageTooLarge) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		photos = append(photos, photo)
+
+		if prompt == "" {
+			prompt = message.Caption
+		}
+	}
+
+	if prompt == "" {
+		prompt = ws.Config.DefaultImagePrompt
+	}
+	if prompt == "" {
+		prompt = "Analyze these images"
+	}
+
+	return m.handleMessage(ctx, ws, chatID, prompt, photos)
+}
@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Prometheus metrics, registered with the default registry
+var (
+	updatesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telecode_updates_total",
+		Help: "Telegram updates handled, by kind (prompt, command, callback).",
+	}, []string{"workspace", "kind"})
+
+	promptsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telecode_prompts_total",
+		Help: "Prompts sent to a CLI.",
+	}, []string{"workspace", "cli"})
+
+	commandErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "telecode_command_errors_total",
+		Help: "CLI invocations that failed, by reason (timeout, canceled, start, exit).",
+	}, []string{"workspace", "cli", "reason"})
+
+	commandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "telecode_command_duration_seconds",
+		Help:    "Duration of CLI invocations.",
+		Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200},
+	}, []string{"workspace", "cli"})
+
+	activeCommands = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "telecode_active_commands",
+		Help: "CLI invocations currently running.",
+	}, []string{"workspace"})
+)
+
+// observeCommand records the metrics of a finished CLI invocation
+func observeCommand(workspace, cli string, result CommandResult, duration time.Duration) {
+	commandDuration.WithLabelValues(workspace, cli).Observe(duration.Seconds())
+
+	reason := ""
+	switch {
+	case errors.Is(result.Err, context.DeadlineExceeded):
+		reason = "timeout"
+	case errors.Is(result.Err, context.Canceled):
+		reason = "canceled"
+	case result.Err != nil:
+		reason = "start"
+	case result.ExitCode != 0:
+		reason = "exit"
+	}
+	if reason != "" {
+		commandErrorsTotal.WithLabelValues(workspace, cli, reason).Inc()
+	}
+}
+
+// startMetricsServer serves /metrics on addr
+func (m *Manager) startMetricsServer(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	m.metricsServer = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	fmt.Printf("📈 Metrics listening on %s\n", addr)
+	go func() {
+		if err := m.metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("❌ Metrics server error: %v\n", err)
+		}
+	}()
+}
+
+// stopMetricsServer shuts the metrics server down, if it was started
+func (m *Manager) stopMetricsServer() {
+	if m.metricsServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.metricsServer.Shutdown(ctx); err != nil {
+		fmt.Printf("⚠️ Failed to stop metrics server: %v\n", err)
+	}
+}
@@ -0,0 +1,194 @@
+package bot
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+// Handler handles a single update for a workspace bot
+type Handler func(ctx context.Context, ws *WorkspaceBot, update telego.Update) error
+
+// Middleware wraps a Handler with behavior shared by all updates, such as
+// authorization or metrics. It may pass the update on to next or stop it.
+type Middleware func(next Handler) Handler
+
+// chain wraps h in middlewares; the first middleware sees an update first
+func chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// defaultMiddlewares returns the middlewares every update passes through
+func (m *Manager) defaultMiddlewares() []Middleware {
+	return []Middleware{dropDuplicates, withTopic, m.requireAuthorization, countUpdates, requireEnabledCommand, m.limitPrompts}
+}
+
+// Use adds middlewares after the default ones. It must be called before Start.
+func (m *Manager) Use(middlewares ...Middleware) {
+	m.middlewares = append(m.middlewares, middlewares...)
+	m.handler = chain(m.dispatch, m.middlewares...)
+}
+
+// messageUserID returns the ID of the user who sent msg, 0 for channel posts
+func messageUserID(msg *telego.Message) int64 {
+	if msg.From == nil {
+		return 0
+	}
+	return msg.From.ID
+}
+
+// maxRecentUpdates is how many update IDs per bot are remembered to detect
+// redelivered updates
+const maxRecentUpdates = 1000
+
+// recentUpdates is a bounded set of the latest update IDs of a bot
+type recentUpdates struct {
+	mu    sync.Mutex
+	seen  map[int]bool
+	order []int // Ring of the IDs in seen, oldest at next
+	next  int
+}
+
+// add records id, reporting false if it was already seen
+func (r *recentUpdates) add(id int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen == nil {
+		r.seen = make(map[int]bool, maxRecentUpdates)
+		r.order = make([]int, 0, maxRecentUpdates)
+	}
+	if r.seen[id] {
+		return false
+	}
+
+	if len(r.order) < maxRecentUpdates {
+		r.order = append(r.order, id)
+	} else {
+		delete(r.seen, r.order[r.next])
+		r.order[r.next] = id
+		r.next = (r.next + 1) % maxRecentUpdates
+	}
+	r.seen[id] = true
+	return true
+}
+
+// dropDuplicates skips updates Telegram delivered again, e.g. after a
+// webhook request timed out, so a prompt never runs twice
+func dropDuplicates(next Handler) Handler {
+	return func(ctx context.Context, ws *WorkspaceBot, update telego.Update) error {
+		if ws.group != nil && !ws.group.recent.add(update.UpdateID) {
+			return nil
+		}
+		return next(ctx, ws, update)
+	}
+}
+
+// withTopic sends replies to messages in a forum topic to the same topic
+func withTopic(next Handler) Handler {
+	return func(ctx context.Context, ws *WorkspaceBot, update telego.Update) error {
+		if update.Message != nil {
+			ctx = withThread(ctx, update.Message)
+		}
+		return next(ctx, ws, update)
+	}
+}
+
+// requireAuthorization ignores messages from chats that aren't allowed and
+// rejects those of unauthorized users. /whoami is answered everywhere, so new
+// users can find the IDs to allowlist. Callback and inline queries check
+// authorization themselves.
+func (m *Manager) requireAuthorization(next Handler) Handler {
+	return func(ctx context.Context, ws *WorkspaceBot, update telego.Update) error {
+		msg := update.Message
+		if msg == nil {
+			return next(ctx, ws, update)
+		}
+
+		chatID, userID := msg.Chat.ID, messageUserID(msg)
+		if getCommandFromMessage(msg.Text) == "/whoami" && ws.Bot.CommandEnabled("/whoami") {
+			return m.handleWhoami(ctx, ws, chatID, userID)
+		}
+		if !ws.Bot.IsAllowed(chatID) {
+			return nil
+		}
+		if !ws.Bot.IsAuthorized(userID) {
+			return sendText(ctx, ws, chatID, ws.Bot.t(chatKey(ctx, chatID), "auth.denied"))
+		}
+		return next(ctx, ws, update)
+	}
+}
+
+// countUpdates counts updates by type for the updates metric
+func countUpdates(next Handler) Handler {
+	return func(ctx context.Context, ws *WorkspaceBot, update telego.Update) error {
+		kind := ""
+		switch {
+		case update.CallbackQuery != nil:
+			kind = "callback"
+		case update.InlineQuery != nil:
+			kind = "inline"
+		case update.Message != nil && isKnownCommand(getCommandFromMessage(update.Message.Text)):
+			kind = "command"
+		case update.Message != nil:
+			kind = "prompt"
+		}
+		if kind != "" {
+			updatesTotal.WithLabelValues(ws.Config.Name, kind).Inc()
+		}
+		return next(ctx, ws, update)
+	}
+}
+
+// requireEnabledCommand rejects commands the workspace disabled
+func requireEnabledCommand(next Handler) Handler {
+	return func(ctx context.Context, ws *WorkspaceBot, update telego.Update) error {
+		if msg := update.Message; msg != nil {
+			if cmd := getCommandFromMessage(msg.Text); isKnownCommand(cmd) && !ws.Bot.CommandEnabled(cmd) {
+				return sendText(ctx, ws, msg.Chat.ID, ws.Bot.t(chatKey(ctx, msg.Chat.ID), "command.disabled"))
+			}
+		}
+		return next(ctx, ws, update)
+	}
+}
+
+// limitPrompts applies the per-user prompt rate limit. Commands other than
+// /retry, /nocache, /compare and /bg, and further photos of an album already
+// let through, aren't counted.
+func (m *Manager) limitPrompts(next Handler) Handler {
+	return func(ctx context.Context, ws *WorkspaceBot, update telego.Update) error {
+		msg := update.Message
+		if msg == nil {
+			return next(ctx, ws, update)
+		}
+
+		cmd := getCommandFromMessage(msg.Text)
+		if isKnownCommand(cmd) && cmd != "/retry" && cmd != "/nocache" && cmd != "/compare" && cmd != "/bg" {
+			return next(ctx, ws, update)
+		}
+
+		var wait time.Duration
+		allow := func() bool {
+			ok, w := ws.Bot.AllowPrompt(messageUserID(msg))
+			wait = w
+			return ok
+		}
+		if msg.MediaGroupID != "" {
+			allowed, first := m.admitAlbumPhoto(ws, msg.MediaGroupID, allow)
+			if allowed {
+				return next(ctx, ws, update)
+			}
+			if !first {
+				return nil // The user was told when the first photo was rejected
+			}
+		} else if allow() {
+			return next(ctx, ws, update)
+		}
+		return sendText(ctx, ws, msg.Chat.ID, ws.Bot.t(chatKey(ctx, msg.Chat.ID), "rate.limited", math.Ceil(wait.Seconds())))
+	}
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"telecode/internal/config"
+)
+
+// albumUpdates returns n updates with photos of one album from user 7
+func albumUpdates(n int) []telego.Update {
+	updates := make([]telego.Update, n)
+	for i := range updates {
+		updates[i] = telego.Update{UpdateID: i + 1, Message: &telego.Message{
+			MessageID:    i + 1,
+			Chat:         telego.Chat{ID: 1},
+			From:         &telego.User{ID: 7},
+			MediaGroupID: "album",
+			Photo:        []telego.PhotoSize{{FileID: "photo"}},
+		}}
+	}
+	return updates
+}
+
+func TestLimitPromptsCountsAlbumOnce(t *testing.T) {
+	tests := []struct {
+		name        string
+		exhausted   bool // The user's only prompt was used before the album
+		wantHandled int64
+		wantReplies int
+	}{
+		{name: "allowed", wantHandled: 5},
+		{name: "rate limited", exhausted: true, wantReplies: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+			ws, fake := newTestWorkspace(t, config.WorkspaceConfig{RateLimit: 1, RateLimitWindow: time.Hour})
+			if tt.exhausted {
+				ws.Bot.AllowPrompt(7)
+			}
+
+			var handled atomic.Int64
+			h := m.limitPrompts(func(context.Context, *WorkspaceBot, telego.Update) error {
+				handled.Add(1)
+				return nil
+			})
+
+			// Photos of an album arrive as concurrent updates
+			var wg sync.WaitGroup
+			for _, update := range albumUpdates(5) {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := h(context.Background(), ws, update); err != nil {
+						t.Error(err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := handled.Load(); got != tt.wantHandled {
+				t.Errorf("handled %d photos, want %d", got, tt.wantHandled)
+			}
+			if got := len(fake.texts()); got != tt.wantReplies {
+				t.Errorf("sent %d rate limit replies, want %d", got, tt.wantReplies)
+			}
+			if ok, _ := ws.Bot.AllowPrompt(7); ok {
+				t.Error("the album wasn't counted against the rate limit")
+			}
+		})
+	}
+}
+
+func TestDropDuplicates(t *testing.T) {
+	ws, _ := newTestWorkspace(t, config.WorkspaceConfig{})
+	ws.group = &botGroup{}
+
+	handled := make(map[int]int)
+	h := chain(func(_ context.Context, _ *WorkspaceBot, update telego.Update) error {
+		handled[update.UpdateID]++
+		return nil
+	}, dropDuplicates)
+
+	update := telego.Update{UpdateID: 42, Message: &telego.Message{Chat: telego.Chat{ID: 1}, Text: "run the tests"}}
+	for i := 0; i < 2; i++ {
+		if err := h(context.Background(), ws, update); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if handled[42] != 1 {
+		t.Errorf("update delivered twice was handled %d times, want once", handled[42])
+	}
+
+	// Only the latest updates are remembered
+	for id := 1000; id < 1000+maxRecentUpdates; id++ {
+		if err := h(context.Background(), ws, telego.Update{UpdateID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h(context.Background(), ws, update); err != nil {
+		t.Fatal(err)
+	}
+	if handled[42] != 2 {
+		t.Errorf("forgotten update was handled %d times, want twice", handled[42])
+	}
+}
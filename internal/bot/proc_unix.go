@@ -0,0 +1,18 @@
+//go:build !windows
+
+package bot
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setupProcessGroup runs cmd in its own session, and so its own process
+// group without a controlling terminal, and makes cancellation kill the whole
+// group, so child processes spawned by the CLI die with it
+func setupProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,152 @@
+//go:build !windows
+
+package bot
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"telecode/internal/config"
+)
+
+func TestRunCommandNonInteractive(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{
+			// A CLI asking for confirmation must not wait for input
+			name:   "stdin is at EOF",
+			script: `if read answer; then echo "read $answer"; else echo eof; fi`,
+			want:   "eof",
+		},
+		{
+			name:   "no controlling terminal",
+			script: `if (exec 3</dev/tty) 2>/dev/null; then echo tty; else echo notty; fi`,
+			want:   "notty",
+		},
+		{
+			name:   "no colors",
+			script: `echo "$TERM $NO_COLOR"`,
+			want:   "dumb 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			result := runCommandWithDir(ctx, []string{"sh", "-c", tt.script}, t.TempDir(), nil, "", nil)
+			if result.Err != nil || result.ExitCode != 0 {
+				t.Fatalf("command failed: %v, exit code %d, stderr %q", result.Err, result.ExitCode, result.Stderr)
+			}
+			if got := strings.TrimSpace(result.Stdout); got != tt.want {
+				t.Errorf("output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleMessageLongPrompt(t *testing.T) {
+	// Claude reads the prompt from stdin; the fake one reports its size
+	installFakeCLIScript(t, "claude", `n=$(wc -c | tr -d ' ')
+echo "{\"type\":\"result\",\"result\":\"got $n bytes\",\"session_id\":\"s\"}"
+`)
+	prompt := strings.Repeat("x", 200*1024)
+
+	tests := []struct {
+		name           string
+		maxPromptBytes int
+		want           string
+	}{
+		{name: "allowed", maxPromptBytes: 300 * 1024, want: "got 204800 bytes"},
+		{name: "too long", maxPromptBytes: 100000, want: "❌ Prompt is too long \\(204800 bytes, max 100000\\)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, fake := newTestWorkspace(t, config.WorkspaceConfig{MaxPromptBytes: tt.maxPromptBytes, CommandTimeout: 10 * time.Second})
+			m := newTestManager(t)
+			m.slots[ws.Config.Name] = make(chan struct{}, 1)
+
+			if err := m.handleMessage(context.Background(), ws, 1, prompt, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			replies := fake.texts()
+			for _, edit := range fake.edited {
+				replies = append(replies, edit.Text)
+			}
+			if !slices.ContainsFunc(replies, func(text string) bool { return strings.Contains(text, tt.want) }) {
+				t.Errorf("replies = %q, want one containing %q", replies, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCommandPromptIsLiteral(t *testing.T) {
+	installFakeCLIScript(t, "aider", `printf '%s\n' "$@"`+"\n")
+	ws, _ := newTestWorkspace(t, config.WorkspaceConfig{DefaultCLI: "aider", AllowedCLIs: []string{"aider"}})
+
+	for _, prompt := range []string{"--help", "; rm -rf .", "$(touch pwned) `touch pwned`"} {
+		t.Run(prompt, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "keep"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cmd, stdin := ws.Bot.BuildCommand(sessionKey{chatID: 1}, prompt, nil)
+			result := runCommandWithDir(context.Background(), cmd, dir, nil, stdin, nil)
+			if result.Err != nil || result.ExitCode != 0 {
+				t.Fatalf("command failed: %v, exit code %d", result.Err, result.ExitCode)
+			}
+
+			if args := strings.Split(strings.TrimSpace(result.Stdout), "\n"); !slices.Contains(args, "--message="+prompt) {
+				t.Errorf("CLI got arguments %q, want the prompt as one", args)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != "keep" {
+				t.Errorf("the prompt ran commands, the working dir holds %v", entries)
+			}
+		})
+	}
+}
+
+func TestRunCommandCancelKillsProcessGroup(t *testing.T) {
+	dir := t.TempDir()
+	ticks := filepath.Join(dir, "ticks")
+	// The background loop stands for a process the CLI spawned
+	script := `(while true; do echo x >> ticks; sleep 0.05; done) & sleep 30`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(300*time.Millisecond, cancel)
+
+	started := time.Now()
+	result := runCommandWithDir(ctx, []string{"sh", "-c", script}, dir, nil, "", nil)
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("command returned %s after it was canceled, want promptly", elapsed-300*time.Millisecond)
+	}
+	if !errors.Is(result.Err, context.Canceled) || result.ExitCode != -1 {
+		t.Errorf("result = %v, exit code %d, want context.Canceled, -1", result.Err, result.ExitCode)
+	}
+
+	// Once killed, the child stops writing
+	size := func() int64 {
+		info, err := os.Stat(ticks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+	before := size()
+	time.Sleep(300 * time.Millisecond)
+	if after := size(); after != before {
+		t.Errorf("child process still running after cancel, wrote %d more bytes", after-before)
+	}
+}
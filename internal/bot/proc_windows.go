@@ -0,0 +1,9 @@
+//go:build windows
+
+package bot
+
+import "os/exec"
+
+// setupProcessGroup is a no-op on Windows, where cancellation kills only the
+// CLI process itself
+func setupProcessGroup(cmd *exec.Cmd) {}
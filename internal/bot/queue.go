@@ -0,0 +1,106 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrQueueFull is returned when a chat already has too many queued commands
+var ErrQueueFull = errors.New("command queue is full")
+
+// ErrDropped is returned to queued commands discarded by DropQueued
+var ErrDropped = errors.New("queued command dropped")
+
+// chatQueue serializes command execution within a chat
+type chatQueue struct {
+	slot    chan struct{} // Holds a token while a command runs
+	drop    chan struct{} // Closed to discard the commands currently waiting
+	waiting int
+}
+
+// AcquireRun waits until no other command is running in the chat. onQueued is
+// called if the command has to wait first. The returned function releases the
+// chat for the next command and must be called once the command is done.
+func (b *Bot) AcquireRun(ctx context.Context, key sessionKey, onQueued func()) (func(), error) {
+	b.queueMu.Lock()
+	q := b.queues[key]
+	if q == nil {
+		q = &chatQueue{slot: make(chan struct{}, 1), drop: make(chan struct{})}
+		b.queues[key] = q
+	}
+	release := func() { <-q.slot }
+
+	// Run immediately if the chat is idle
+	select {
+	case q.slot <- struct{}{}:
+		b.queueMu.Unlock()
+		return release, nil
+	default:
+	}
+
+	if q.waiting >= b.queueSize {
+		b.queueMu.Unlock()
+		return nil, ErrQueueFull
+	}
+	q.waiting++
+	drop := q.drop
+	b.queueMu.Unlock()
+
+	defer func() {
+		b.queueMu.Lock()
+		q.waiting--
+		b.queueMu.Unlock()
+	}()
+
+	if onQueued != nil {
+		onQueued()
+	}
+
+	select {
+	case q.slot <- struct{}{}:
+		return release, nil
+	case <-drop:
+		return nil, ErrDropped
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// DropQueued discards all commands waiting in the chat's queue
+func (b *Bot) DropQueued(key sessionKey) {
+	b.queueMu.Lock()
+	defer b.queueMu.Unlock()
+	if q := b.queues[key]; q != nil {
+		close(q.drop)
+		q.drop = make(chan struct{})
+	}
+}
+
+// RunState describes what the chat is doing: "idle", "running (Xs)",
+// "running (Xs), N queued" with commands waiting behind it, or
+// "queued (N ahead)" while commands wait for a run that is just finishing
+func (b *Bot) RunState(key sessionKey) string {
+	b.runningMu.Lock()
+	run := b.running[key]
+	b.runningMu.Unlock()
+
+	b.queueMu.Lock()
+	waiting := 0
+	if q := b.queues[key]; q != nil {
+		waiting = q.waiting
+	}
+	b.queueMu.Unlock()
+
+	if run == nil {
+		if waiting > 0 {
+			return b.t(key, "state.queued", waiting)
+		}
+		return b.t(key, "state.idle")
+	}
+	state := b.t(key, "state.running", time.Since(run.started).Round(time.Second))
+	if waiting > 0 {
+		state += b.t(key, "state.waiting", waiting)
+	}
+	return state
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket rate limiter keyed by user ID. Each user may
+// send up to limit prompts at once, and regains one every window/limit.
+type rateLimiter struct {
+	mu      sync.Mutex
+	limit   float64
+	rate    float64 // Tokens regained per second
+	buckets map[int64]*tokenBucket
+}
+
+// tokenBucket holds the tokens left for a single user
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// newRateLimiter creates a limiter allowing limit prompts per window. It
+// returns nil (no limit) when limit or window is not positive.
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	if limit <= 0 || window <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		limit:   float64(limit),
+		rate:    float64(limit) / window.Seconds(),
+		buckets: make(map[int64]*tokenBucket),
+	}
+}
+
+// Allow takes a token for the user. If none is left, it returns false and
+// how long until the next one is available.
+func (l *rateLimiter) Allow(userID int64) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	bucket := l.buckets[userID]
+	if bucket == nil {
+		bucket = &tokenBucket{tokens: l.limit, updated: now}
+		l.buckets[userID] = bucket
+	}
+
+	// Refill for the time passed since the last prompt
+	bucket.tokens += now.Sub(bucket.updated).Seconds() * l.rate
+	if bucket.tokens > l.limit {
+		bucket.tokens = l.limit
+	}
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
@@ -0,0 +1,108 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mymmrac/telego"
+	ta "github.com/mymmrac/telego/telegoapi"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+const (
+	// retryStartDelay is the first backoff delay for server errors
+	retryStartDelay = 500 * time.Millisecond
+	// retryMaxDelay caps how long a single retry waits
+	retryMaxDelay = 30 * time.Second
+)
+
+// retryDelay returns how long to wait before retrying a failed Telegram call,
+// or false if the error is not worth retrying
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	backoff := retryStartDelay << attempt
+	if backoff > retryMaxDelay {
+		backoff = retryMaxDelay
+	}
+
+	var apiErr *ta.Error
+	if !errors.As(err, &apiErr) {
+		// Network errors are usually transient
+		return backoff, true
+	}
+
+	switch {
+	case apiErr.ErrorCode == http.StatusTooManyRequests:
+		if apiErr.Parameters != nil && apiErr.Parameters.RetryAfter > 0 {
+			delay := time.Duration(apiErr.Parameters.RetryAfter) * time.Second
+			if delay > retryMaxDelay {
+				delay = retryMaxDelay
+			}
+			return delay, true
+		}
+		return backoff, true
+	case apiErr.ErrorCode >= http.StatusInternalServerError:
+		return backoff, true
+	default:
+		// Other client errors fail the same way every time
+		return 0, false
+	}
+}
+
+// withRetry calls fn until it succeeds, retrying up to retries times on rate
+// limits and transient errors
+func withRetry(ctx context.Context, retries int, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries {
+			return err
+		}
+
+		delay, ok := retryDelay(err, attempt)
+		if !ok {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// sendWithRetry sends a message, retrying on rate limits and transient errors.
+// The message goes to the forum topic of ctx, if any.
+func (ws *WorkspaceBot) sendWithRetry(ctx context.Context, params *telego.SendMessageParams) (*telego.Message, error) {
+	if params.MessageThreadID == 0 {
+		params.MessageThreadID = threadID(ctx)
+	}
+	var msg *telego.Message
+	err := withRetry(ctx, ws.Config.SendRetries, func() error {
+		var err error
+		msg, err = ws.TgBot.SendMessage(ctx, params)
+		return err
+	})
+	return msg, err
+}
+
+// editWithRetry edits a message text, retrying on rate limits and transient errors
+func (ws *WorkspaceBot) editWithRetry(ctx context.Context, params *telego.EditMessageTextParams) error {
+	return withRetry(ctx, ws.Config.SendRetries, func() error {
+		_, err := ws.TgBot.EditMessageText(ctx, params)
+		return err
+	})
+}
+
+// sendDocumentWithRetry uploads data as a document named name to the chat or
+// the forum topic of ctx, retrying on rate limits and transient errors
+func (ws *WorkspaceBot) sendDocumentWithRetry(ctx context.Context, chatID int64, name string, data []byte, caption string) error {
+	return withRetry(ctx, ws.Config.SendRetries, func() error {
+		// The upload reader is consumed, so every attempt needs a fresh one
+		params := tu.Document(tu.ID(chatID), tu.FileFromBytes(data, name)).WithCaption(caption).
+			WithMessageThreadID(threadID(ctx))
+		_, err := ws.TgBot.SendDocument(ctx, params)
+		return err
+	})
+}
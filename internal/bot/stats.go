@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"time"
+
+	"telecode/internal/executor"
+)
+
+// ChatStats counts the CLI runs of a chat since Since
+type ChatStats struct {
+	Prompts  int
+	Failures int // Runs that errored, timed out or exited non-zero
+	Duration time.Duration
+	Since    time.Time
+
+	// Token counts, as far as the CLI reports them
+	InputTokens   int
+	OutputTokens  int
+	UsageReported bool
+}
+
+// RecordRun adds a finished CLI run to the chat's statistics
+func (b *Bot) RecordRun(key sessionKey, failed bool, duration time.Duration) {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+
+	stats, ok := b.chatStats[key]
+	if !ok {
+		stats.Since = time.Now()
+	}
+	stats.Prompts++
+	if failed {
+		stats.Failures++
+	}
+	stats.Duration += duration
+	b.chatStats[key] = stats
+}
+
+// recordUsage adds the token usage of a run to the chat's statistics
+func (b *Bot) recordUsage(key sessionKey, usage executor.Usage) {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+
+	stats, ok := b.chatStats[key]
+	if !ok {
+		stats.Since = time.Now()
+	}
+	stats.InputTokens += usage.InputTokens
+	stats.OutputTokens += usage.OutputTokens
+	stats.UsageReported = true
+	b.chatStats[key] = stats
+}
+
+// EstimatedCost returns the cost of the tokens used at the given rates
+func (s ChatStats) EstimatedCost(perInputToken, perOutputToken float64) float64 {
+	return float64(s.InputTokens)*perInputToken + float64(s.OutputTokens)*perOutputToken
+}
+
+// GetChatStats returns the chat's statistics; ok is false if nothing was
+// recorded since the last reset
+func (b *Bot) GetChatStats(key sessionKey) (stats ChatStats, ok bool) {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+	stats, ok = b.chatStats[key]
+	return stats, ok
+}
+
+// ResetStats zeros the chat's statistics
+func (b *Bot) ResetStats(key sessionKey) {
+	b.statsMu.Lock()
+	defer b.statsMu.Unlock()
+	delete(b.chatStats, key)
+}
@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"telecode/internal/config"
+	"telecode/internal/executor"
+)
+
+func TestStatsReset(t *testing.T) {
+	ws, fake := newTestWorkspace(t, config.WorkspaceConfig{})
+	chat, other := sessionKey{chatID: 1}, sessionKey{chatID: 2}
+	for _, key := range []sessionKey{chat, other} {
+		ws.Bot.RecordRun(key, false, time.Second)
+		ws.Bot.RecordRun(key, true, 2*time.Second)
+		ws.Bot.recordUsage(key, executor.Usage{InputTokens: 100, OutputTokens: 20})
+	}
+
+	if err := (&Manager{}).handleStats(context.Background(), ws, 1, "/stats reset"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fake.texts(); len(got) != 1 || got[0] != "📊 Statistics reset\\." {
+		t.Errorf("replies = %q, want the reset confirmation", got)
+	}
+	if stats, ok := ws.Bot.GetChatStats(chat); ok || stats != (ChatStats{}) {
+		t.Errorf("stats after reset = %+v, %v, want zero", stats, ok)
+	}
+	stats, _ := ws.Bot.GetChatStats(other)
+	want := ChatStats{Prompts: 2, Failures: 1, Duration: 3 * time.Second, Since: stats.Since, InputTokens: 100, OutputTokens: 20, UsageReported: true}
+	if stats != want || stats.Since.IsZero() {
+		t.Errorf("stats of another chat = %+v, want %+v", stats, want)
+	}
+
+	// Counting starts over after a reset
+	ws.Bot.RecordRun(chat, false, time.Second)
+	if stats, _ := ws.Bot.GetChatStats(chat); stats.Prompts != 1 || stats.Failures != 0 || stats.InputTokens != 0 {
+		t.Errorf("stats after a new run = %+v, want one prompt", stats)
+	}
+}
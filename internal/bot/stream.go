@@ -0,0 +1,244 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+// streamInterval is the minimum delay between two edits of streamed output
+const streamInterval = time.Second
+
+// sentMessage is a message already delivered by a messageStreamer
+type sentMessage struct {
+	id   int
+	text string
+}
+
+// messageStreamer progressively renders command output into Telegram
+// messages. The last message is edited as new content arrives and output
+// spills into new messages once it exceeds maxMessageLength, so only the
+// first message of an answer triggers a notification.
+type messageStreamer struct {
+	ctx    context.Context
+	ws     *WorkspaceBot
+	chatID int64
+
+	mu     sync.Mutex
+	output strings.Builder // Raw command output so far
+	dirty  bool
+
+	// format turns the raw output into the text shown while streaming. It
+	// runs at most once per streamInterval, not for every line of output.
+	format  func(output string) string
+	preview string
+
+	sent    []sentMessage
+	done    chan struct{}
+	stopped chan struct{}
+
+	// maxChunks limits how many messages an answer may span, 0 is unlimited
+	maxChunks int
+	truncated bool
+	// chunkSize is halved whenever Telegram rejects a chunk as too long
+	chunkSize int
+
+	// frames are cycled through as the placeholder until the first output
+	// arrives, one per streamInterval
+	frames []string
+	frame  int
+}
+
+// thinkingPlaceholder is shown until the first output arrives
+const thinkingPlaceholder = "🤔 Thinking..."
+
+// defaultThinkingFrames is the placeholder animation of thinking_animation
+var defaultThinkingFrames = []string{"🤔 Thinking.", "🤔 Thinking..", "🤔 Thinking..."}
+
+// newMessageStreamer creates a streamer and starts its render loop. A
+// placeholder message is sent right away and later edited into the first
+// chunk of the answer. Streamed output is shown as format renders it, or as
+// is if format is nil.
+func newMessageStreamer(ctx context.Context, ws *WorkspaceBot, chatID int64, format func(output string) string) *messageStreamer {
+	if format == nil {
+		format = func(output string) string { return output }
+	}
+	s := &messageStreamer{
+		ctx:       ctx,
+		ws:        ws,
+		chatID:    chatID,
+		format:    format,
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+		maxChunks: ws.Config.MaxOutputChunks,
+		chunkSize: maxMessageLength,
+	}
+
+	placeholder := thinkingPlaceholder
+	if ws.Config.ThinkingAnimation {
+		s.frames = defaultThinkingFrames
+		if len(ws.Config.ThinkingFrames) > 0 {
+			s.frames = ws.Config.ThinkingFrames
+		}
+		placeholder = s.frames[0]
+	}
+
+	msg, err := sendOutputChunk(ctx, ws, chatID, placeholder)
+	if err == nil {
+		s.sent = append(s.sent, sentMessage{id: msg.MessageID, text: placeholder})
+	}
+
+	go s.loop()
+	return s
+}
+
+// Append adds command output; it is rendered on the next tick
+func (s *messageStreamer) Append(output string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.output.WriteString(output)
+	s.dirty = true
+}
+
+// Finish stops streaming and renders the complete text
+func (s *messageStreamer) Finish(text string) error {
+	close(s.done)
+	<-s.stopped
+
+	key := chatKey(s.ctx, s.chatID)
+	if strings.TrimSpace(text) == "" {
+		text = s.ws.Bot.t(key, "reply.empty")
+	}
+
+	// Long answers read better as a file than as a stream of messages
+	if threshold := s.ws.Config.FileThresholdBytes; threshold > 0 && len(text) > threshold {
+		notice := s.ws.Bot.t(key, "reply.as_file", len(text))
+		if err := s.render(notice); err != nil {
+			return err
+		}
+		return s.ws.sendDocumentWithRetry(s.ctx, s.chatID, "response.txt", []byte(text), "")
+	}
+
+	if err := s.render(text); err != nil {
+		return err
+	}
+
+	// Nothing is lost when the chat only shows part of the answer
+	if s.truncated {
+		return s.ws.sendDocumentWithRetry(s.ctx, s.chatID, "output.txt", []byte(text), s.ws.Bot.t(key, "reply.full_output"))
+	}
+	return nil
+}
+
+// loop renders pending updates, debounced to one edit per streamInterval
+func (s *messageStreamer) loop() {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(streamInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			output, dirty := s.output.String(), s.dirty
+			s.dirty = false
+			s.mu.Unlock()
+
+			if dirty {
+				s.preview = s.format(output)
+			}
+			text := s.preview
+
+			// Keep the placeholder moving until output arrives
+			if text == "" && len(s.frames) > 1 {
+				s.frame = (s.frame + 1) % len(s.frames)
+				text, dirty = s.frames[s.frame], true
+			}
+
+			if dirty {
+				if err := s.render(text); err != nil {
+					fmt.Printf("⚠️ Failed to stream output: %v\n", err)
+				}
+			}
+		}
+	}
+}
+
+// splitSmaller halves the chunk size if err rejected a chunk as too long,
+// reporting whether the text should be rendered again with smaller chunks
+func (s *messageStreamer) splitSmaller(err error) bool {
+	if !isTooLongError(err) || s.chunkSize <= minSplitLength {
+		return false
+	}
+	s.chunkSize /= 2
+	return true
+}
+
+// render brings the sent messages in line with text, editing messages whose
+// content changed and sending new ones for additional chunks. When Telegram
+// rejects a chunk as too long, the text is rendered again in smaller chunks.
+func (s *messageStreamer) render(text string) error {
+	trimmedText := strings.TrimSpace(text)
+	if trimmedText == "" {
+		return nil
+	}
+
+	var chunks []string
+	for _, chunk := range chunkString(trimmedText, s.chunkSize) {
+		// Ensure chunk is not empty after trimming
+		if strings.TrimSpace(chunk) != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	s.truncated = s.maxChunks > 0 && len(chunks) > s.maxChunks
+	if s.truncated {
+		omitted := len(chunks) - s.maxChunks
+		chunks = append(chunks[:s.maxChunks], s.ws.Bot.t(chatKey(s.ctx, s.chatID), "reply.truncated", omitted))
+	}
+
+	for i, chunk := range chunks {
+		if i < len(s.sent) {
+			if s.sent[i].text == chunk {
+				continue
+			}
+			err := editOutputChunk(s.ctx, s.ws, s.chatID, s.sent[i].id, chunk)
+			if s.splitSmaller(err) {
+				return s.render(text)
+			}
+			if err != nil {
+				return err
+			}
+			s.sent[i].text = chunk
+			continue
+		}
+
+		msg, err := sendOutputChunk(s.ctx, s.ws, s.chatID, chunk)
+		if s.splitSmaller(err) {
+			return s.render(text)
+		}
+		if err != nil {
+			return err
+		}
+		s.sent = append(s.sent, sentMessage{id: msg.MessageID, text: chunk})
+	}
+
+	// Remove messages left over from a longer preview
+	for _, msg := range s.sent[len(chunks):] {
+		_ = s.ws.TgBot.DeleteMessage(s.ctx, &telego.DeleteMessageParams{
+			ChatID:    tu.ID(s.chatID),
+			MessageID: msg.id,
+		})
+	}
+	s.sent = s.sent[:len(chunks)]
+
+	return nil
+}
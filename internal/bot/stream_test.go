@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"telecode/internal/config"
+)
+
+func TestStreamerResplitsTooLong(t *testing.T) {
+	const limit = 2500
+	ws, fake := newTestWorkspace(t, config.WorkspaceConfig{})
+	tooLong := 0
+	fake.sendErr = func(text string) error {
+		if len(text) > limit {
+			tooLong++
+			return tooLongError
+		}
+		return nil
+	}
+
+	paragraphs := make([]string, 7)
+	for i := range paragraphs {
+		paragraphs[i] = strings.Repeat(string(rune('a'+i)), 1000)
+	}
+	text := strings.Join(paragraphs, "\n\n")
+
+	s := newMessageStreamer(context.Background(), ws, 1, nil)
+	if err := s.Finish(text); err != nil {
+		t.Fatal(err)
+	}
+
+	if tooLong == 0 {
+		t.Fatal("no chunk was rejected as too long")
+	}
+	var got []string
+	for _, msg := range s.sent {
+		if len(msg.text) > limit {
+			t.Errorf("sent a chunk of %d bytes, over the limit of %d", len(msg.text), limit)
+		}
+		got = append(got, msg.text)
+	}
+	if joined := strings.Join(got, "\n\n"); joined != text {
+		t.Errorf("messages hold %d bytes of the answer, want all %d", len(joined), len(text))
+	}
+	if len(fake.edited) == 0 {
+		t.Error("the placeholder was not edited into the first chunk")
+	}
+}
+
+func TestStreamerFileThreshold(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantDoc bool
+	}{
+		{name: "short", text: strings.Repeat("a", 100)},
+		{name: "long", text: strings.Repeat("a", 101), wantDoc: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, fake := newTestWorkspace(t, config.WorkspaceConfig{FileThresholdBytes: 100})
+
+			s := newMessageStreamer(context.Background(), ws, 1, nil)
+			if err := s.Finish(tt.text); err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.wantDoc {
+				if len(fake.documents) != 0 {
+					t.Errorf("sent %d documents, want none", len(fake.documents))
+				}
+				if len(s.sent) != 1 || s.sent[0].text != tt.text {
+					t.Errorf("messages = %v, want the answer", s.sent)
+				}
+				return
+			}
+
+			if len(fake.documents) != 1 {
+				t.Fatalf("sent %d documents, want 1", len(fake.documents))
+			}
+			doc := fake.documents[0].Document.File
+			if doc.Name() != "response.txt" {
+				t.Errorf("document is named %q, want response.txt", doc.Name())
+			}
+			if data, _ := io.ReadAll(doc); string(data) != tt.text {
+				t.Errorf("document holds %d bytes, want the %d of the answer", len(data), len(tt.text))
+			}
+			if len(s.sent) != 1 || !strings.Contains(s.sent[0].text, "sent as response.txt") {
+				t.Errorf("messages = %v, want the notice only", s.sent)
+			}
+		})
+	}
+}
+
+func TestStreamerFormatsOncePerEdit(t *testing.T) {
+	ws, fake := newTestWorkspace(t, config.WorkspaceConfig{})
+	var formats atomic.Int32
+	format := func(output string) string {
+		formats.Add(1)
+		return strings.ToUpper(output)
+	}
+
+	started := time.Now()
+	s := newMessageStreamer(context.Background(), ws, 1, format)
+	for i := 0; i < 1000; i++ {
+		s.Append(fmt.Sprintf("line %d\n", i))
+	}
+	time.Sleep(streamInterval + streamInterval/2)
+	elapsed := time.Since(started)
+	if err := s.Finish("done"); err != nil {
+		t.Fatal(err)
+	}
+
+	ticks := int32(elapsed / streamInterval)
+	if n := formats.Load(); n == 0 || n > ticks {
+		t.Errorf("output was formatted %d times for 1000 lines in %d ticks, want once per tick at most", n, ticks)
+	}
+	if len(fake.edited) == 0 || !strings.HasPrefix(fake.edited[0].Text, "LINE 0") {
+		t.Errorf("edits = %d, want the formatted preview shown", len(fake.edited))
+	}
+}
@@ -0,0 +1,146 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxTasks is how many background tasks /tasks lists per chat
+const maxTasks = 10
+
+// taskStatus is the state of a background task
+type taskStatus string
+
+const (
+	taskRunning taskStatus = "running"
+	taskDone    taskStatus = "done"
+	taskFailed  taskStatus = "failed"
+)
+
+// Task is a prompt started with /bg
+type Task struct {
+	ID       int
+	Prompt   string
+	CLI      string
+	Status   taskStatus
+	Started  time.Time
+	Finished time.Time // Zero while running
+
+	cancel context.CancelFunc
+}
+
+// StartTask records a background task of the chat and returns it. cancel
+// stops the task on /cancel. Only the latest maxTasks tasks are kept.
+func (b *Bot) StartTask(key sessionKey, cli, prompt string, cancel context.CancelFunc) Task {
+	b.tasksMu.Lock()
+	defer b.tasksMu.Unlock()
+	b.lastTaskID++
+	task := Task{ID: b.lastTaskID, Prompt: prompt, CLI: cli, Status: taskRunning, Started: time.Now(), cancel: cancel}
+	tasks := append(b.tasks[key], task)
+	if len(tasks) > maxTasks {
+		tasks = tasks[len(tasks)-maxTasks:]
+	}
+	b.tasks[key] = tasks
+	return task
+}
+
+// FinishTask marks a background task of the chat as done or failed
+func (b *Bot) FinishTask(key sessionKey, id int, ok bool) {
+	b.tasksMu.Lock()
+	defer b.tasksMu.Unlock()
+	for i := range b.tasks[key] {
+		task := &b.tasks[key][i]
+		if task.ID != id {
+			continue
+		}
+		task.Status = taskDone
+		if !ok {
+			task.Status = taskFailed
+		}
+		task.Finished = time.Now()
+	}
+}
+
+// CancelTasks cancels the running background tasks of the chat, returning
+// how many there were
+func (b *Bot) CancelTasks(key sessionKey) int {
+	b.tasksMu.Lock()
+	defer b.tasksMu.Unlock()
+	canceled := 0
+	for _, task := range b.tasks[key] {
+		if task.Status == taskRunning {
+			task.cancel()
+			canceled++
+		}
+	}
+	return canceled
+}
+
+// Tasks returns the background tasks of the chat, oldest first
+func (b *Bot) Tasks(key sessionKey) []Task {
+	b.tasksMu.Lock()
+	defer b.tasksMu.Unlock()
+	return append([]Task(nil), b.tasks[key]...)
+}
+
+// handleBackground handles the /bg command, running a prompt as a background
+// task with the chat's CLI and model but without its session. The chat can
+// keep sending prompts, and the answer is sent once the task finished.
+// /cancel stops the task along with the chat's running prompt.
+func (m *Manager) handleBackground(ctx context.Context, ws *WorkspaceBot, chatID int64, text string) error {
+	key := chatKey(ctx, chatID)
+	prompt := strings.TrimSpace(strings.TrimPrefix(text, getCommandFromMessage(text)))
+	if prompt == "" {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "bg.usage"))
+	}
+	if len(prompt) > ws.Config.MaxPromptBytes {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "prompt.too_long", len(prompt), ws.Config.MaxPromptBytes))
+	}
+
+	// Every update runs in its own handler, so waiting here blocks nothing
+	cmdCtx, cancel := context.WithTimeout(ctx, ws.Config.CommandTimeout)
+	defer cancel()
+
+	cli := ws.Bot.GetCLI(key)
+	task := ws.Bot.StartTask(key, cli, prompt, cancel)
+	if err := sendText(ctx, ws, chatID, ws.Bot.t(key, "bg.started", task.ID)); err != nil {
+		ws.Bot.FinishTask(key, task.ID, false)
+		return err
+	}
+
+	reply, ok := m.runOneOff(ctx, cmdCtx, ws, chatID, cli, ws.Bot.GetModel(key), prompt)
+	ws.Bot.FinishTask(key, task.ID, ok)
+
+	status := "✅"
+	if !ok {
+		status = "❌"
+	}
+	header := ws.Bot.t(key, "bg.finished", status, task.ID, truncateText(prompt, 60))
+	return sendChunks(ctx, ws, chatID, header+"\n\n"+reply)
+}
+
+// handleTasks handles the /tasks command, listing the chat's background tasks
+func (m *Manager) handleTasks(ctx context.Context, ws *WorkspaceBot, chatID int64) error {
+	key := chatKey(ctx, chatID)
+	tasks := ws.Bot.Tasks(key)
+	if len(tasks) == 0 {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "tasks.none"))
+	}
+
+	var sb strings.Builder
+	sb.WriteString(ws.Bot.t(key, "tasks.title") + "\n")
+	for _, task := range tasks {
+		var elapsed time.Duration
+		switch task.Status {
+		case taskRunning:
+			elapsed = time.Since(task.Started)
+		default:
+			elapsed = task.Finished.Sub(task.Started)
+		}
+		sb.WriteString(fmt.Sprintf("\n#%d %s (%s, %s): %s",
+			task.ID, ws.Bot.t(key, "tasks."+string(task.Status)), task.CLI, elapsed.Round(time.Second), truncateText(task.Prompt, 80)))
+	}
+	return sendText(ctx, ws, chatID, sb.String())
+}
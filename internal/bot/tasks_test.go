@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"telecode/internal/config"
+)
+
+func TestHandleBackgroundModelAndCancel(t *testing.T) {
+	argsFile := filepath.Join(t.TempDir(), "args")
+	installFakeCLIScript(t, "claude", `printf '%s\n' "$@" > `+argsFile+`.tmp
+mv `+argsFile+`.tmp `+argsFile+`
+sleep 30
+`)
+	ws, fake := newTestWorkspace(t, config.WorkspaceConfig{
+		DefaultModel:   "sonnet",
+		AllowedModels:  []string{"sonnet", "opus"},
+		CommandTimeout: time.Minute,
+		MaxPromptBytes: 1000,
+	})
+	m := newTestManager(t)
+	m.slots[ws.Config.Name] = make(chan struct{}, 1)
+	key := sessionKey{chatID: 1}
+	if err := ws.Bot.SetModel(key, "opus"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- m.handleBackground(context.Background(), ws, 1, "/bg hello")
+	}()
+
+	// Wait for the CLI to start
+	var args []byte
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		var err error
+		if args, err = os.ReadFile(argsFile); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the background task never ran the CLI")
+		}
+	}
+	if !strings.Contains(string(args), "--model=opus\n") {
+		t.Errorf("CLI arguments %q don't use the chat's model opus", args)
+	}
+
+	if err := m.handleCancel(context.Background(), ws, 1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("/cancel didn't stop the background task")
+	}
+
+	if tasks := ws.Bot.Tasks(key); len(tasks) != 1 || tasks[0].Status != taskFailed {
+		t.Errorf("tasks = %+v, want one failed task", tasks)
+	}
+	texts := fake.texts()
+	if last := texts[len(texts)-1]; !strings.Contains(last, "Command canceled") {
+		t.Errorf("last reply = %q, want the task reported as canceled", last)
+	}
+}
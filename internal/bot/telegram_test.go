@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/mymmrac/telego"
+	ta "github.com/mymmrac/telego/telegoapi"
+
+	"telecode/internal/config"
+)
+
+// fakeTelegram is a TelegramAPI that records the calls made to it
+type fakeTelegram struct {
+	mu        sync.Mutex
+	sent      []*telego.SendMessageParams
+	edited    []*telego.EditMessageTextParams
+	deleted   []*telego.DeleteMessageParams
+	documents []*telego.SendDocumentParams
+	answers   []*telego.AnswerCallbackQueryParams
+	nextID    int
+
+	// sendErr, if set, is called for every message sent or edited; a
+	// non-nil error rejects the message instead of recording it
+	sendErr func(text string) error
+}
+
+func (f *fakeTelegram) SendMessage(_ context.Context, params *telego.SendMessageParams) (*telego.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendErr != nil {
+		if err := f.sendErr(params.Text); err != nil {
+			return nil, err
+		}
+	}
+	f.sent = append(f.sent, params)
+	f.nextID++
+	return &telego.Message{MessageID: f.nextID, Text: params.Text}, nil
+}
+
+func (f *fakeTelegram) EditMessageText(_ context.Context, params *telego.EditMessageTextParams) (*telego.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendErr != nil {
+		if err := f.sendErr(params.Text); err != nil {
+			return nil, err
+		}
+	}
+	f.edited = append(f.edited, params)
+	return &telego.Message{MessageID: params.MessageID, Text: params.Text}, nil
+}
+
+func (f *fakeTelegram) DeleteMessage(_ context.Context, params *telego.DeleteMessageParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, params)
+	return nil
+}
+
+func (f *fakeTelegram) SendDocument(_ context.Context, params *telego.SendDocumentParams) (*telego.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.documents = append(f.documents, params)
+	f.nextID++
+	return &telego.Message{MessageID: f.nextID}, nil
+}
+
+func (f *fakeTelegram) SendChatAction(context.Context, *telego.SendChatActionParams) error {
+	return nil
+}
+
+func (f *fakeTelegram) GetFile(_ context.Context, params *telego.GetFileParams) (*telego.File, error) {
+	return &telego.File{FileID: params.FileID, FilePath: params.FileID}, nil
+}
+
+func (f *fakeTelegram) AnswerCallbackQuery(_ context.Context, params *telego.AnswerCallbackQueryParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.answers = append(f.answers, params)
+	return nil
+}
+
+func (f *fakeTelegram) AnswerInlineQuery(context.Context, *telego.AnswerInlineQueryParams) error {
+	return nil
+}
+
+// texts returns the text of every message sent
+func (f *fakeTelegram) texts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	texts := make([]string, len(f.sent))
+	for i, params := range f.sent {
+		texts[i] = params.Text
+	}
+	return texts
+}
+
+// tooLongError is the error Telegram returns for a message over its limit
+var tooLongError = &ta.Error{ErrorCode: http.StatusBadRequest, Description: "Bad Request: message is too long"}
+
+// newTestWorkspace returns a workspace talking to a fakeTelegram. Fields of
+// cfg left empty get the defaults LoadConfig would set.
+func newTestWorkspace(t *testing.T, cfg config.WorkspaceConfig) (*WorkspaceBot, *fakeTelegram) {
+	t.Helper()
+	if cfg.Name == "" {
+		cfg.Name = "test"
+	}
+	if cfg.WorkingDir == "" {
+		cfg.WorkingDir = t.TempDir()
+	}
+	if cfg.AllowedRoot == "" {
+		cfg.AllowedRoot = cfg.WorkingDir
+	}
+	if cfg.TempDir == "" {
+		cfg.TempDir = t.TempDir()
+	}
+	if cfg.DefaultCLI == "" {
+		cfg.DefaultCLI = "claude"
+	}
+	if len(cfg.AllowedCLIs) == 0 {
+		cfg.AllowedCLIs = []string{"claude", "opencode"}
+	}
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = 5
+	}
+	if cfg.MaxConcurrent == 0 {
+		cfg.MaxConcurrent = 1
+	}
+	if cfg.CLIPrefix == "" {
+		cfg.CLIPrefix = "!"
+	}
+
+	fake := &fakeTelegram{}
+	return &WorkspaceBot{Config: cfg, Bot: NewBot(cfg), TgBot: fake}, fake
+}
+
+// installFakeCLI puts an executable named name on PATH, so the bot
+// considers that CLI installed
+func installFakeCLI(t *testing.T, name string) {
+	t.Helper()
+	installFakeCLIScript(t, name, "")
+}
+
+// installFakeCLIScript puts a shell script named name on PATH
+func installFakeCLIScript(t *testing.T, name, script string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// newTestManager returns a manager without workspaces, for running updates
+// through middlewares and handlers
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	m, err := NewManager(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
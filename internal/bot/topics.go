@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mymmrac/telego"
+)
+
+// threadKey is the context key of the forum topic replies are sent to
+type threadKey struct{}
+
+// withThread returns a copy of ctx whose replies go to the forum topic of msg.
+// Messages outside forum topics leave ctx unchanged.
+func withThread(ctx context.Context, msg *telego.Message) context.Context {
+	if msg == nil || !msg.IsTopicMessage || msg.MessageThreadID == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, threadKey{}, msg.MessageThreadID)
+}
+
+// threadID returns the forum topic replies made with ctx are sent to, or 0
+// for the chat itself
+func threadID(ctx context.Context) int {
+	id, _ := ctx.Value(threadKey{}).(int)
+	return id
+}
+
+// sessionKey identifies a conversation, which has its own CLI session and
+// settings: a chat, or one forum topic of a chat
+type sessionKey struct {
+	chatID   int64
+	threadID int // 0 outside forum topics, e.g. in private chats
+}
+
+// chatKey returns the conversation of chatID that ctx replies to
+func chatKey(ctx context.Context, chatID int64) sessionKey {
+	return sessionKey{chatID: chatID, threadID: threadID(ctx)}
+}
+
+// String returns the chat ID, followed by "/<thread ID>" for forum topics
+func (k sessionKey) String() string {
+	if k.threadID == 0 {
+		return strconv.FormatInt(k.chatID, 10)
+	}
+	return fmt.Sprintf("%d/%d", k.chatID, k.threadID)
+}
+
+// MarshalText encodes the key like String. This keeps the sessions file
+// saved before topics had their own sessions readable.
+func (k sessionKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText decodes a key encoded by MarshalText
+func (k *sessionKey) UnmarshalText(text []byte) error {
+	chat, thread, hasThread := strings.Cut(string(text), "/")
+	chatID, err := strconv.ParseInt(chat, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID %q: %w", chat, err)
+	}
+	k.chatID, k.threadID = chatID, 0
+	if hasThread {
+		if k.threadID, err = strconv.Atoi(thread); err != nil {
+			return fmt.Errorf("invalid thread ID %q: %w", thread, err)
+		}
+	}
+	return nil
+}
@@ -1,85 +1,505 @@
 package bot
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"telecode/internal/executor"
 )
 
+// ansiRegex matches terminal escape sequences: CSI sequences (colors, cursor
+// movements, modes like \x1b[?25l), OSC sequences (terminal titles, links)
+// terminated by BEL or ST, character set selections like \x1b(B and the
+// remaining two-character escapes
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[()#][0-9A-Za-z]|\x1b[0-9=>@-Z\\-_]`)
+
 // stripAnsiCodes removes ANSI escape sequences and OSC sequences from text
 func stripAnsiCodes(text string) string {
-	// Remove ANSI escape sequences (color codes, cursor movements, etc.)
-	ansiRegex := regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
-	// Remove OSC sequences (terminal title changes, etc.)
-	oscRegex := regexp.MustCompile(`\x1b\][0-9;]*.*?\x07`)
-	// Remove any remaining escape sequences
-	escapeRegex := regexp.MustCompile(`\x1b\[[\?0-9]*[hl]`)
-
-	text = ansiRegex.ReplaceAllString(text, "")
-	text = oscRegex.ReplaceAllString(text, "")
-	text = escapeRegex.ReplaceAllString(text, "")
-	return text
+	if !strings.Contains(text, "\x1b") {
+		return text
+	}
+	return ansiRegex.ReplaceAllString(text, "")
 }
 
-// runCommandWithDir executes a CLI command in a specific working directory
-func runCommandWithDir(cmd []string, workingDir string, timeout time.Duration) string {
-	if len(cmd) == 0 {
-		return "Error: Command is empty"
+// cleanOutput strips escape sequences from command output unless the
+// workspace disabled it
+func cleanOutput(ws *WorkspaceBot, text string) string {
+	if ws.Config.DisableANSIStrip {
+		return text
 	}
+	return stripAnsiCodes(text)
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+// markdownV2Reserved lists the characters that must be escaped in MarkdownV2
+const markdownV2Reserved = "\\_*[]()~`>#+-=|{}.!"
+
+// escapeMarkdownV2 escapes text for use in a MarkdownV2 message
+func escapeMarkdownV2(text string) string {
+	var sb strings.Builder
+	sb.Grow(len(text))
+	for _, r := range text {
+		if strings.ContainsRune(markdownV2Reserved, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// escapeMarkdownV2Code escapes text for use inside a MarkdownV2 code span or
+// pre block, where only backticks and backslashes are special
+func escapeMarkdownV2Code(text string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(text)
+}
+
+// formatMarkdownV2 renders plain text as MarkdownV2, escaping everything
+// except fenced code blocks, which are kept as pre blocks
+func formatMarkdownV2(text string) string {
+	var sb strings.Builder
+	inCode := false
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		trimmed := strings.TrimSpace(line)
+		isFence := strings.HasPrefix(trimmed, codeFence)
+		switch {
+		case isFence && !inCode:
+			inCode = true
+			// Telegram takes the language from the first word of the info string
+			lang, _, _ := strings.Cut(fenceInfo(trimmed), " ")
+			sb.WriteString(codeFence + escapeMarkdownV2Code(lang))
+		case isFence && inCode && fenceInfo(trimmed) == "":
+			inCode = false
+			sb.WriteString(codeFence)
+		case inCode:
+			sb.WriteString(escapeMarkdownV2Code(line))
+		default:
+			sb.WriteString(escapeMarkdownV2(line))
+		}
+	}
+	// Close a block left open by the CLI so Telegram accepts the message
+	if inCode {
+		sb.WriteString("\n" + codeFence)
+	}
+	return sb.String()
+}
+
+// truncateText shortens text to at most max runes, collapsing whitespace so
+// the result fits on a single line
+func truncateText(text string, max int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= max {
+		return string(runes)
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// outputWriter collects command output and reports each line once it is
+// complete
+type outputWriter struct {
+	buf      strings.Builder
+	reported int // Length of the output already passed to onOutput
+	onOutput func(lines string)
+}
+
+// Write implements io.Writer
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	if w.onOutput != nil && bytes.IndexByte(p, '\n') >= 0 {
+		output := w.buf.String()
+		end := strings.LastIndexByte(output, '\n') + 1
+		w.onOutput(output[w.reported:end])
+		w.reported = end
+	}
+	return len(p), nil
+}
+
+// nonInteractiveEnv asks CLIs for plain output without colors or terminal
+// features; the workspace env can still override it
+var nonInteractiveEnv = []string{"TERM=dumb", "NO_COLOR=1"}
+
+// commandEnv returns the environment of a command: the process environment
+// with nonInteractiveEnv and env added. Values may be secrets and must never
+// be logged.
+func commandEnv(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Later entries take precedence over inherited ones
+	environ := append(os.Environ(), nonInteractiveEnv...)
+	for _, key := range keys {
+		environ = append(environ, key+"="+env[key])
+	}
+	return environ
+}
+
+// CommandResult holds the outcome of a CLI invocation
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	// Err is set when the command could not run to completion: it failed to
+	// start, was canceled, or timed out (context.DeadlineExceeded)
+	Err error
+}
+
+// runCommandWithDir executes a CLI command in a specific working directory.
+// The command and its children are killed when ctx is canceled or its
+// deadline expires, so callers set the timeout on ctx; any output produced up
+// to that point is still returned.
+// The command runs without a terminal, reading stdin if it is not empty and no
+// input otherwise. Variables in env are added to the inherited environment.
+// If onOutput is not nil, it is called with the lines of stdout completed
+// since its last call each time the command writes a line break.
+func runCommandWithDir(ctx context.Context, cmd []string, workingDir string, env map[string]string, stdin string, onOutput func(lines string)) CommandResult {
+	if len(cmd) == 0 {
+		return CommandResult{ExitCode: -1, Err: fmt.Errorf("command is empty")}
+	}
 
 	command := exec.CommandContext(ctx, cmd[0], cmd[1:]...)
 	command.Dir = workingDir // Set working directory
-	output, err := command.CombinedOutput()
+	command.Env = commandEnv(env)
+	// Without input a CLI asking for confirmation reads EOF instead of hanging
+	if stdin != "" {
+		command.Stdin = strings.NewReader(stdin)
+	}
+	setupProcessGroup(command)
+	// Don't wait forever on pipes held open by orphaned child processes
+	command.WaitDelay = 5 * time.Second
+	stdout := &outputWriter{onOutput: onOutput}
+	var stderr strings.Builder
+	command.Stdout = stdout
+	command.Stderr = &stderr
+	err := command.Run()
 
-	if ctx.Err() == context.DeadlineExceeded {
-		return fmt.Sprintf("Error: Command execution timeout (%v)", timeout)
+	result := CommandResult{
+		Stdout: stdout.buf.String(),
+		Stderr: stderr.String(),
 	}
 
-	if err != nil {
-		return stripAnsiCodes(fmt.Sprintf("Error: %v\n%s", err, string(output)))
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		result.ExitCode = -1
+		result.Err = ctx.Err()
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		result.ExitCode = -1
+		result.Err = err
+	}
+
+	return result
+}
+
+// formatCommandResult builds the reply for a finished command from its
+// (already extracted) stdout. Failures are reported with the exit code and
+// stderr, while successful runs only show stdout.
+func formatCommandResult(result CommandResult, stdout string, timeout time.Duration) string {
+	switch {
+	case errors.Is(result.Err, context.DeadlineExceeded):
+		return fmt.Sprintf("%s\n\n⏱ Command timed out after %.0fs", stdout, timeout.Seconds())
+	case errors.Is(result.Err, context.Canceled):
+		return fmt.Sprintf("🛑 Command canceled\n%s", stdout)
+	case result.Err != nil:
+		return fmt.Sprintf("Error: %v\n%s", result.Err, stdout)
+	case result.ExitCode != 0:
+		text := fmt.Sprintf("⚠️ exited with code %d", result.ExitCode)
+		if strings.TrimSpace(stdout) != "" {
+			text += "\n\n" + strings.TrimSpace(stdout)
+		}
+		if stderr := strings.TrimSpace(result.Stderr); stderr != "" {
+			text += "\n\n" + codeFence + "\n" + stderr + "\n" + codeFence
+		}
+		return text
+	default:
+		return stdout
 	}
+}
 
-	return stripAnsiCodes(string(output))
+// maxDetailArgLength is how much of each argument verbose mode shows
+const maxDetailArgLength = 200
+
+// quoteArg quotes a command argument if it is empty or contains whitespace
+// or shell metacharacters, so command lines can be read unambiguously
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\;&|<>$`*?") {
+		return strconv.Quote(arg)
+	}
+	return arg
 }
 
-// extractTextFromOpenCodeJSON parses OpenCode JSON output and extracts text responses
-func extractTextFromOpenCodeJSON(output string) string {
-	var texts []string
-	scanner := bufio.NewScanner(strings.NewReader(output))
+// formatDryRun describes the command a prompt would run, for dry-run mode:
+// every argument on its own line, the working directory and the stdin input
+func formatDryRun(cmd []string, stdin, workDir string) string {
+	args := make([]string, len(cmd))
+	for i, arg := range cmd {
+		args[i] = quoteArg(arg)
+	}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
+	text := "🧪 Dry run, nothing was executed\n\n" + codeFence + "\n" + strings.Join(args, " \\\n  ") + "\n" + codeFence +
+		"\n📁 Working directory: " + workDir
+	if stdin != "" {
+		text += "\n📥 Stdin:\n" + codeFence + "\n" + stdin + "\n" + codeFence
+	}
+	return text
+}
+
+// formatRunDetails describes a finished run for verbose mode as MarkdownV2:
+// the command line, the size of its stdin, its duration and exit code
+func formatRunDetails(cmd []string, stdin string, result CommandResult, duration time.Duration) string {
+	args := make([]string, len(cmd))
+	for i, arg := range cmd {
+		if runes := []rune(arg); len(runes) > maxDetailArgLength {
+			arg = string(runes[:maxDetailArgLength]) + "…"
 		}
+		args[i] = quoteArg(arg)
+	}
 
-		var event map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &event); err != nil {
-			// Not a JSON line, skip
-			continue
+	var sb strings.Builder
+	sb.WriteString(codeFence + "\n" + escapeMarkdownV2Code(strings.Join(args, " ")) + "\n" + codeFence + "\n")
+	if stdin != "" {
+		sb.WriteString(escapeMarkdownV2(fmt.Sprintf("📥 Prompt on stdin (%d bytes)", len(stdin))) + "\n")
+	}
+	status := fmt.Sprintf("exit code %d", result.ExitCode)
+	if result.Err != nil {
+		status = result.Err.Error()
+	}
+	sb.WriteString(escapeMarkdownV2(fmt.Sprintf("⏱ %.1fs, %s", duration.Seconds(), status)))
+	return sb.String()
+}
+
+// formatOutput turns the raw stdout of a CLI into the text shown to the
+// user. The executor reduces the output to the response text, and output that
+// is a single JSON document is pretty-printed in a json code block.
+func formatOutput(exec executor.Executor, raw string) string {
+	output := raw
+	if exec != nil {
+		if text, ok := exec.ExtractText(output); ok {
+			output = text
 		}
+	}
 
-		// Extract text from text events
-		if eventType, ok := event["type"].(string); ok && eventType == "text" {
-			if part, ok := event["part"].(map[string]interface{}); ok {
-				if text, ok := part["text"].(string); ok && text != "" {
-					texts = append(texts, text)
-				}
-			}
+	trimmed := strings.TrimSpace(output)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return output
+	}
+	var pretty bytes.Buffer
+	if err := json.Indent(&pretty, []byte(trimmed), "", "  "); err != nil {
+		return output // Not valid JSON
+	}
+	return codeFence + "json\n" + pretty.String() + "\n" + codeFence
+}
+
+// previewOutput renders partial command output for streaming
+func previewOutput(exec executor.Executor, output string) string {
+	if exec == nil {
+		return output
+	}
+	// Raw events aren't meaningful to users, only show the text so far
+	text, _ := exec.ExtractText(output)
+	return text
+}
+
+// toolActions describes common Claude Code tools for the tool trace
+var toolActions = map[string]struct{ verb, singular, plural string }{
+	"Read":      {"Read", "file", "files"},
+	"Edit":      {"Edited", "file", "files"},
+	"MultiEdit": {"Edited", "file", "files"},
+	"Write":     {"Wrote", "file", "files"},
+	"Bash":      {"Ran", "command", "commands"},
+	"Grep":      {"Searched", "time", "times"},
+	"Glob":      {"Listed files", "time", "times"},
+	"WebFetch":  {"Fetched", "page", "pages"},
+	"WebSearch": {"Searched the web", "time", "times"},
+}
+
+// summarizeToolCalls condenses tool calls into one line, e.g.
+// "🔧 Read 3 files, ran 2 commands". Empty if no tools were called.
+func summarizeToolCalls(tools []string) string {
+	counts := make(map[string]int)
+	var order []string
+	for _, tool := range tools {
+		if counts[tool] == 0 {
+			order = append(order, tool)
+		}
+		counts[tool]++
+	}
+
+	parts := make([]string, 0, len(order))
+	for _, tool := range order {
+		n := counts[tool]
+		action, ok := toolActions[tool]
+		if !ok {
+			action.verb, action.singular, action.plural = "Used "+tool, "time", "times"
+		}
+		noun := action.plural
+		if n == 1 {
+			noun = action.singular
+		}
+		verb := action.verb
+		if len(parts) > 0 {
+			verb = strings.ToLower(verb[:1]) + verb[1:]
 		}
+		parts = append(parts, fmt.Sprintf("%s %d %s", verb, n, noun))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "🔧 " + strings.Join(parts, ", ")
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a partially written file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
 	}
 
-	if len(texts) == 0 {
-		return output // Return original if no text found
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
 	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed
 
-	return strings.Join(texts, "\n\n")
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// transcribeAudio runs the transcription command on an audio file and returns
+// the recognized text. The "{file}" placeholder in the command is replaced by
+// the file path, which is appended when no placeholder is present.
+func transcribeAudio(ctx context.Context, command []string, audioPath string, timeout time.Duration) (string, error) {
+	if len(command) == 0 {
+		return "", fmt.Errorf("transcription command is empty")
+	}
+
+	args := make([]string, 0, len(command)+1)
+	hasPlaceholder := false
+	for _, arg := range command {
+		if strings.Contains(arg, "{file}") {
+			hasPlaceholder = true
+			arg = strings.ReplaceAll(arg, "{file}", audioPath)
+		}
+		args = append(args, arg)
+	}
+	if !hasPlaceholder {
+		args = append(args, audioPath)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	setupProcessGroup(cmd)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stripAnsiCodes(string(output))), nil
+}
+
+// postProcessTimeout limits how long the post-processing command may run
+const postProcessTimeout = 30 * time.Second
+
+// postProcess pipes output through the post-processing command and returns
+// what it writes to stdout
+func postProcess(ctx context.Context, command []string, output string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, postProcessTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	setupProcessGroup(cmd)
+	cmd.Stdin = strings.NewReader(output)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	processed, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(processed), nil
+}
+
+// applyPostProcess runs the workspace's post_process command on output. The
+// output is returned unchanged if none is configured or the command fails.
+func applyPostProcess(ctx context.Context, ws *WorkspaceBot, output string) string {
+	if len(ws.Config.PostProcess) == 0 {
+		return output
+	}
+	processed, err := postProcess(ctx, ws.Config.PostProcess, output)
+	if err != nil {
+		fmt.Printf("⚠️ Workspace %s: post_process failed, sending raw output: %v\n", ws.Config.Name, err)
+		return output
+	}
+	return processed
+}
+
+// textMimeTypes lists non text/* MIME types that are still plain text
+var textMimeTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/yaml":       true,
+	"application/x-yaml":     true,
+	"application/toml":       true,
+	"application/javascript": true,
+	"application/x-sh":       true,
+	"application/sql":        true,
+}
+
+// isTextMimeType reports whether a MIME type denotes a text file
+func isTextMimeType(mimeType string) bool {
+	mimeType = strings.TrimSpace(strings.Split(mimeType, ";")[0])
+	return strings.HasPrefix(mimeType, "text/") || textMimeTypes[mimeType]
+}
+
+// isTextFile sniffs the beginning of a file to check that it is text
+func isTextFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, err := f.Read(buf)
+	if err != nil && err != io.EOF {
+		return false
+	}
+	return isTextMimeType(http.DetectContentType(buf[:n]))
 }
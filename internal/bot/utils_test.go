@@ -0,0 +1,120 @@
+package bot
+
+import (
+	"slices"
+	"testing"
+
+	"telecode/internal/config"
+)
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "every reserved character",
+			text: "_*[]()~`>#+-=|{}.!\\",
+			want: "\\_\\*\\[\\]\\(\\)\\~\\`\\>\\#\\+\\-\\=\\|\\{\\}\\.\\!\\\\",
+		},
+		{
+			name: "plain text",
+			text: "hello world 123 안녕",
+			want: "hello world 123 안녕",
+		},
+		{
+			name: "cli output",
+			text: "Fixed bug_fix.go (2 files) - done!",
+			want: "Fixed bug\\_fix\\.go \\(2 files\\) \\- done\\!",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeMarkdownV2(tt.text); got != tt.want {
+				t.Errorf("escapeMarkdownV2(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatMarkdownV2(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "code blocks are kept",
+			text: "Run *this*:\n```sh\necho a_b `x`\n```",
+			want: "Run \\*this\\*:\n```sh\necho a_b \\`x\\`\n```",
+		},
+		{
+			name: "unclosed block is closed",
+			text: "```\nx.y",
+			want: "```\nx.y\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatMarkdownV2(tt.text); got != tt.want {
+				t.Errorf("formatMarkdownV2(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripAnsiCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "plain", text: "no colors here", want: "no colors here"},
+		{name: "reset", text: "done\x1b[0m", want: "done"},
+		{name: "sgr", text: "\x1b[1;32m✔ 12 passed\x1b[0m, \x1b[31m1 failed\x1b[39m", want: "✔ 12 passed, 1 failed"},
+		{name: "256 colors", text: "\x1b[38;5;208mwarning\x1b[m", want: "warning"},
+		{name: "true color", text: "\x1b[48;2;10;20;30mbg\x1b[49m", want: "bg"},
+		{name: "cursor and modes", text: "\x1b[?25l\x1b[2K\x1b[1Gprogress\x1b[?25h", want: "progress"},
+		{name: "osc title and link", text: "\x1b]0;title\x07\x1b]8;;https://example.com\x1b\\link\x1b]8;;\x1b\\", want: "link"},
+		{name: "charset", text: "\x1b(Bbox", want: "box"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripAnsiCodes(tt.text); got != tt.want {
+				t.Errorf("stripAnsiCodes(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanOutput(t *testing.T) {
+	colored := "\x1b[32mok\x1b[0m"
+	if got := cleanOutput(&WorkspaceBot{}, colored); got != "ok" {
+		t.Errorf("cleanOutput = %q, want escape codes stripped", got)
+	}
+	ws := &WorkspaceBot{Config: config.WorkspaceConfig{DisableANSIStrip: true}}
+	if got := cleanOutput(ws, colored); got != colored {
+		t.Errorf("cleanOutput with disable_ansi_strip = %q, want it unchanged", got)
+	}
+}
+
+func TestOutputWriterReportsLinesOnce(t *testing.T) {
+	var reported []string
+	w := &outputWriter{onOutput: func(lines string) { reported = append(reported, lines) }}
+
+	for _, p := range []string{"par", "tial\nnext", " line\n", "a\nb\nc", "\n"} {
+		w.Write([]byte(p))
+	}
+
+	want := []string{"partial\n", "next line\n", "a\nb\n", "c\n"}
+	if !slices.Equal(reported, want) {
+		t.Errorf("reported %q, want %q", reported, want)
+	}
+	if got := w.buf.String(); got != "partial\nnext line\na\nb\nc\n" {
+		t.Errorf("collected %q, want all of the output", got)
+	}
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+// ffmpegTimeout limits each ffmpeg run extracting a frame or audio track
+const ffmpegTimeout = 2 * time.Minute
+
+// handleVideoMessage handles videos by extracting a representative frame for
+// image analysis and, with transcribe_command set, transcribing the audio
+// track. Both are sent to the CLI with the caption as the prompt.
+func (m *Manager) handleVideoMessage(ctx context.Context, ws *WorkspaceBot, message *telego.Message) error {
+	chatID := message.Chat.ID
+	key := chatKey(ctx, chatID)
+	video := message.Video
+
+	if ws.Config.FFmpegBinary == "" {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "video.not_configured"))
+	}
+
+	// Check limits before downloading
+	if video.FileSize > ws.Config.MaxVideoBytes {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "video.too_large", ws.Config.MaxVideoBytes/1024))
+	}
+	if limit := ws.Config.MaxVideoDuration; limit > 0 && time.Duration(video.Duration)*time.Second > limit {
+		return sendText(ctx, ws, chatID, ws.Bot.t(key, "video.too_long", limit))
+	}
+
+	// Get file info
+	file, err := ws.TgBot.GetFile(ctx, &telego.GetFileParams{FileID: video.FileID})
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "media.info_failed", ws.Bot.t(key, "media.video")))
+		return err
+	}
+
+	// Download to temp file
+	ext := filepath.Ext(filepath.Base(video.FileName))
+	if ext == "" || strings.Contains(ext, "*") {
+		ext = ".mp4"
+	}
+	videoPath, err := downloadToTemp(ctx, ws, file.FilePath, "telecode_video_*"+ext)
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "media.download_failed", ws.Bot.t(key, "media.video")))
+		return err
+	}
+	defer os.Remove(videoPath) // Clean up temp file
+
+	framePath, err := extractWithFFmpeg(ctx, ws, videoPath, "telecode_frame_*.jpg", "-vf", "thumbnail", "-frames:v", "1")
+	if err != nil {
+		_ = sendText(ctx, ws, chatID, ws.Bot.t(key, "video.frame_failed"))
+		return err
+	}
+	defer os.Remove(framePath) // Clean up temp file
+
+	// Process prompt
+	prompt := message.Caption
+	if prompt == "" {
+		prompt = "Describe this video, shown by one of its frames"
+	}
+
+	// Videos without sound have no audio track to extract
+	if len(ws.Config.TranscribeCommand) > 0 {
+		if transcript := transcribeVideo(ctx, ws, videoPath); transcript != "" {
+			prompt += "\n\nTranscript of the video's audio:\n" + transcript
+		}
+	}
+
+	// The frame isn't a Telegram file, so /retry can't download it again
+	return m.handleMessage(ctx, ws, chatID, prompt, []attachment{{Path: framePath}})
+}
+
+// transcribeVideo transcribes the audio track of a video, returning an empty
+// transcript if it has none or transcription failed
+func transcribeVideo(ctx context.Context, ws *WorkspaceBot, videoPath string) string {
+	audioPath, err := extractWithFFmpeg(ctx, ws, videoPath, "telecode_video_audio_*.wav", "-vn", "-ac", "1", "-ar", "16000")
+	if err != nil {
+		return ""
+	}
+	defer os.Remove(audioPath) // Clean up temp file
+
+	transcript, err := transcribeAudio(ctx, ws.Config.TranscribeCommand, audioPath, ws.Config.CommandTimeout)
+	if err != nil {
+		fmt.Printf("⚠️ Failed to transcribe video audio: %v\n", err)
+		return ""
+	}
+	return transcript
+}
+
+// extractWithFFmpeg runs ffmpeg on input with args, writing its output into
+// a new temp file named after pattern, and returns the file's path
+func extractWithFFmpeg(ctx context.Context, ws *WorkspaceBot, input, pattern string, args ...string) (string, error) {
+	out, err := os.CreateTemp(ws.Config.TempDir, pattern)
+	if err != nil {
+		return "", err
+	}
+	out.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+	cmd := append([]string{ws.Config.FFmpegBinary, "-y", "-loglevel", "error", "-i", input}, args...)
+	result := runCommandWithDir(ctx, append(cmd, out.Name()), ws.Config.TempDir, nil, "", nil)
+	if result.Err == nil && result.ExitCode != 0 {
+		result.Err = fmt.Errorf("ffmpeg exited with code %d: %s", result.ExitCode, strings.TrimSpace(result.Stderr))
+	}
+	if result.Err != nil {
+		os.Remove(out.Name())
+		return "", result.Err
+	}
+	return out.Name(), nil
+}
@@ -0,0 +1,74 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+)
+
+// webhookPath returns the path webhook updates of a group are posted to
+func webhookPath(group *botGroup) string {
+	return "/webhook/" + url.PathEscape(group.workspaces[0].Config.Name)
+}
+
+// startWebhooks registers a webhook for every bot and serves them on
+// m.webhook.Addr. Updates are routed like long polling ones.
+func (m *Manager) startWebhooks(ctx context.Context) error {
+	mux := http.NewServeMux()
+	baseURL := strings.TrimSuffix(m.webhook.URL, "/")
+
+	for _, group := range m.groups {
+		// Telegram sends the secret in a header, so requests not coming
+		// from Telegram are rejected
+		secret := m.webhook.Secret
+		if secret == "" {
+			secret = group.TgBot.SecretToken()
+		}
+		path := webhookPath(group)
+
+		updates, err := group.TgBot.UpdatesViaWebhook(ctx,
+			telego.WebhookHTTPServeMux(mux, "POST "+path, secret),
+			telego.WithWebhookSet(ctx, &telego.SetWebhookParams{
+				URL:         baseURL + path,
+				SecretToken: secret,
+			}),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set webhook for workspace %s: %w", strings.Join(group.names(), ", "), err)
+		}
+
+		fmt.Printf("🪝 Webhook for %s: %s\n", strings.Join(group.names(), ", "), baseURL+path)
+		go m.processUpdates(ctx, group, updates)
+	}
+
+	m.webhookServer = &http.Server{
+		Addr:              m.webhook.Addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := m.webhookServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("❌ Webhook server error: %v\n", err)
+		}
+	}()
+
+	return nil
+}
+
+// stopWebhookServer stops accepting webhook updates, if the server was started
+func (m *Manager) stopWebhookServer() {
+	if m.webhookServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.webhookServer.Shutdown(ctx); err != nil {
+		fmt.Printf("⚠️ Failed to stop webhook server: %v\n", err)
+	}
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GetWorkDir returns the directory commands of a chat run in
+func (b *Bot) GetWorkDir(key sessionKey) string {
+	b.settingsMu.RLock()
+	defer b.settingsMu.RUnlock()
+	if dir := b.chatSettings[key].WorkDir; dir != "" {
+		return dir
+	}
+	return b.workingDir
+}
+
+// SetWorkDir changes the chat's working directory to path, resolved relative
+// to its current one. The directory must exist and lie within the allowed
+// root. The session is reset, as CLIs keep sessions per directory.
+func (b *Bot) SetWorkDir(key sessionKey, path string) (string, error) {
+	dir := path
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(b.GetWorkDir(key), dir)
+	}
+
+	// Resolve symlinks so a link can't point outside the root
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("directory '%s' does not exist", path)
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("'%s' is not a directory", path)
+	}
+	root, err := filepath.EvalSymlinks(b.allowedRoot)
+	if err != nil {
+		return "", fmt.Errorf("allowed root is not accessible: %w", err)
+	}
+	if !isWithin(root, resolved) {
+		return "", fmt.Errorf("'%s' is outside of %s", path, b.allowedRoot)
+	}
+
+	b.settingsMu.Lock()
+	settings := b.chatSettings[key]
+	settings.WorkDir = resolved
+	b.chatSettings[key] = settings
+	b.sessionMgr.Delete(key)
+	b.settingsMu.Unlock()
+
+	b.persistSessions()
+	return resolved, nil
+}
+
+// isWithin reports whether path is root or one of its subdirectories
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
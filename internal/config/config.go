@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -10,18 +13,134 @@ import (
 
 // WorkspaceConfig represents a single workspace/bot configuration
 type WorkspaceConfig struct {
-	Name           string        `yaml:"name"`
-	WorkingDir     string        `yaml:"working_dir"`
-	BotToken       string        `yaml:"bot_token"`
-	AllowedChats   []int64       `yaml:"allowed_chats,omitempty"`
-	DefaultCLI     string        `yaml:"default_cli,omitempty"`
-	CommandTimeout time.Duration `yaml:"command_timeout,omitempty"`
-	Model          string        `yaml:"model,omitempty"`
+	Name           string              `yaml:"name"`
+	WorkingDir     string              `yaml:"working_dir"`
+	AllowedRoot    string              `yaml:"allowed_root,omitempty"` // /cd stays within this directory
+	BotToken       string              `yaml:"bot_token"`
+	BotTokenFile   string              `yaml:"bot_token_file,omitempty"` // Read the token from this file instead
+	BotTokenEnv    string              `yaml:"bot_token_env,omitempty"`  // Read the token from this variable instead
+	AllowedChats   []int64             `yaml:"allowed_chats,omitempty"`
+	AllowedUsers   []int64             `yaml:"allowed_users,omitempty"`
+	AdminUsers     []int64             `yaml:"admin_users,omitempty"` // May use admin commands like /env
+	DefaultCLI     string              `yaml:"default_cli,omitempty"`
+	AllowedCLIs    []string            `yaml:"allowed_clis,omitempty"`  // CLIs telecode may run
+	GeminiBinary   string              `yaml:"gemini_binary,omitempty"` // Gemini CLI executable
+	CommandTimeout time.Duration       `yaml:"command_timeout,omitempty"`
+	Model          string              `yaml:"model,omitempty"`         // Same as default_model
+	DefaultModel   string              `yaml:"default_model,omitempty"` // Chats and new sessions start with it
+	SystemPrompt   string              `yaml:"system_prompt,omitempty"` // Added to every prompt
+	ExtraArgs      map[string][]string `yaml:"extra_args,omitempty"`    // Extra flags per CLI
+	Env            map[string]string   `yaml:"env,omitempty"`           // Added to the CLI's environment
+	AllowedModels  []string            `yaml:"allowed_models,omitempty"`
+	SessionFile    string              `yaml:"session_file,omitempty"`
+	// TranscribeCommand transcribes voice messages; "{file}" is replaced by
+	// the audio file path (appended if absent) and stdout is used as the prompt
+	TranscribeCommand []string `yaml:"transcribe_command,omitempty"`
+	// PostProcess filters CLI output before it is sent: the output is written
+	// to its stdin and its stdout is sent instead
+	PostProcess           []string `yaml:"post_process,omitempty"`
+	ConfirmReset          bool     `yaml:"confirm_reset,omitempty"`           // Ask before /new discards a session
+	ReplyContext          bool     `yaml:"reply_context,omitempty"`           // Quote replied-to messages in the prompt
+	ForwardContext        bool     `yaml:"forward_context,omitempty"`         // Name the original sender of forwarded messages
+	ShowToolTrace         bool     `yaml:"show_tool_trace,omitempty"`         // Summarize the agent's tool calls
+	DisableTypingAction   bool     `yaml:"disable_typing_action,omitempty"`   // Don't show "typing…" while a command runs
+	DisableANSIStrip      bool     `yaml:"disable_ansi_strip,omitempty"`      // Keep escape codes in CLI output
+	DefaultImagePrompt    string   `yaml:"default_image_prompt,omitempty"`    // Used for photos without caption
+	DefaultDocumentPrompt string   `yaml:"default_document_prompt,omitempty"` // Used for documents without caption
+	MaxImageBytes         int64    `yaml:"max_image_bytes,omitempty"`
+	MaxDocumentBytes      int64    `yaml:"max_document_bytes,omitempty"`
+	QueueSize             int      `yaml:"queue_size,omitempty"`
+	SendRetries           int      `yaml:"send_retries,omitempty"`
+	TempDir               string   `yaml:"temp_dir,omitempty"`
+	HistorySize           int      `yaml:"history_size,omitempty"`
+	MaxConcurrent         int      `yaml:"max_concurrent,omitempty"`
+	// MaxOutputChunks limits the messages one answer is split into; the full
+	// output is attached as a file when exceeded (0 disables the limit)
+	MaxOutputChunks int `yaml:"max_output_chunks,omitempty"`
+	// FileThresholdBytes sends answers longer than this as response.txt
+	// instead of messages (0 disables)
+	FileThresholdBytes int `yaml:"file_threshold_bytes,omitempty"`
+	// Audio files longer than MaxAudioDuration (0 disables) or larger than
+	// MaxAudioBytes aren't transcribed
+	MaxAudioDuration time.Duration `yaml:"max_audio_duration,omitempty"`
+	MaxAudioBytes    int64         `yaml:"max_audio_bytes,omitempty"`
+	// FFmpegBinary enables videos, which are sent to the CLI as a frame and
+	// the transcript of their audio. Longer or larger videos than
+	// MaxVideoDuration (0 disables) or MaxVideoBytes are rejected.
+	FFmpegBinary     string        `yaml:"ffmpeg_binary,omitempty"`
+	MaxVideoDuration time.Duration `yaml:"max_video_duration,omitempty"`
+	MaxVideoBytes    int64         `yaml:"max_video_bytes,omitempty"`
+	// RateLimit allows each user this many prompts per RateLimitWindow
+	// (0 disables the limit). Commands are not limited.
+	RateLimit       int           `yaml:"rate_limit,omitempty"`
+	RateLimitWindow time.Duration `yaml:"rate_limit_window,omitempty"`
+	// Rates for the cost estimate in /stats, in dollars per token
+	CostPerInputToken  float64 `yaml:"cost_per_input_token,omitempty"`
+	CostPerOutputToken float64 `yaml:"cost_per_output_token,omitempty"`
+	// CollectMode combines messages sent within CollectWindow into one
+	// prompt; chats can toggle it with /collect
+	CollectMode   bool          `yaml:"collect_mode,omitempty"`
+	CollectWindow time.Duration `yaml:"collect_window,omitempty"`
+	// InlineQueries answers "@bot prompt" queries with a one-off CLI run
+	// that must finish within InlineTimeout
+	InlineQueries bool          `yaml:"inline_queries,omitempty"`
+	InlineTimeout time.Duration `yaml:"inline_timeout,omitempty"`
+	// MaxPromptBytes rejects longer prompts. CLIs that can't read the prompt
+	// from stdin get it as a single argument, which Linux caps at 128KiB.
+	MaxPromptBytes int `yaml:"max_prompt_bytes,omitempty"`
+	// CacheTTL answers a prompt sent again within this time with the cached
+	// reply instead of running the CLI (0 disables caching). At most
+	// CacheMaxEntries replies are kept.
+	CacheTTL        time.Duration `yaml:"cache_ttl,omitempty"`
+	CacheMaxEntries int           `yaml:"cache_max_entries,omitempty"`
+	// ThinkingAnimation cycles the placeholder shown until output arrives
+	// through ThinkingFrames, one per second
+	ThinkingAnimation bool     `yaml:"thinking_animation,omitempty"`
+	ThinkingFrames    []string `yaml:"thinking_frames,omitempty"`
+	// AttachChangedFiles lists the files a run created or modified after
+	// its answer. /files sends files of up to MaxAttachBytes.
+	AttachChangedFiles bool  `yaml:"attach_changed_files,omitempty"`
+	MaxAttachBytes     int64 `yaml:"max_attach_bytes,omitempty"`
+	// AllowGit enables /commit, which commits all changes of the working
+	// directory
+	AllowGit bool `yaml:"allow_git,omitempty"`
+	// SessionIdleTimeout starts a new session for a chat that sent no
+	// prompt for this long (0 disables)
+	SessionIdleTimeout time.Duration `yaml:"session_idle_timeout,omitempty"`
+	// AllowCompare enables /compare, which runs a prompt with both claude
+	// and opencode and so costs twice as much
+	AllowCompare bool `yaml:"allow_compare,omitempty"`
+	// EnabledCommands turns on commands that are off by default (/commit,
+	// /compare); DisabledCommands turns off any built-in command
+	EnabledCommands  []string `yaml:"enabled_commands,omitempty"`
+	DisabledCommands []string `yaml:"disabled_commands,omitempty"`
+	// CLIPrefix followed by a CLI name, as in "!opencode fix the tests", runs
+	// that one prompt with the named CLI, without the chat's session
+	CLIPrefix string `yaml:"cli_prefix,omitempty"`
+	// WelcomeMessage greets a new chat on its first /start
+	WelcomeMessage string `yaml:"welcome_message,omitempty"`
 }
 
 // Config represents the complete telecode configuration
 type Config struct {
-	Workspaces []WorkspaceConfig `yaml:"workspaces"`
+	Workspaces      []WorkspaceConfig `yaml:"workspaces"`
+	ShutdownTimeout time.Duration     `yaml:"shutdown_timeout,omitempty"`
+	LogFormat       string            `yaml:"log_format,omitempty"`   // "text" or "json"
+	HealthAddr      string            `yaml:"health_addr,omitempty"`  // Empty disables health checks
+	MetricsAddr     string            `yaml:"metrics_addr,omitempty"` // Empty disables Prometheus metrics
+	Webhook         WebhookConfig     `yaml:",inline"`
+}
+
+// WebhookConfig configures how updates are received from Telegram
+type WebhookConfig struct {
+	Mode string `yaml:"mode,omitempty"` // "polling" (default) or "webhook"
+	// URL is the public base URL Telegram posts updates to, e.g.
+	// https://bot.example.com. Each bot is served under /webhook/<name>.
+	URL string `yaml:"webhook_url,omitempty"`
+	// Addr is the local address of the webhook server, e.g. :8443
+	Addr string `yaml:"webhook_addr,omitempty"`
+	// Secret verifies incoming requests; defaults to a hash of the bot token
+	Secret string `yaml:"webhook_secret,omitempty"`
 }
 
 // LoadConfig loads configuration from a YAML file
@@ -37,24 +156,156 @@ func LoadConfig(path string) (*Config, error) {
 	}
 
 	// Set defaults and validate
+	if cfg.MetricsAddr != "" && cfg.MetricsAddr == cfg.HealthAddr {
+		return nil, fmt.Errorf("metrics_addr and health_addr must differ")
+	}
+	switch cfg.Webhook.Mode {
+	case "":
+		cfg.Webhook.Mode = "polling"
+	case "polling":
+	case "webhook":
+		if cfg.Webhook.URL == "" || cfg.Webhook.Addr == "" {
+			return nil, fmt.Errorf("webhook mode requires webhook_url and webhook_addr")
+		}
+	default:
+		return nil, fmt.Errorf("mode must be polling or webhook, got %q", cfg.Webhook.Mode)
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 30 * time.Second
+	}
+	switch cfg.LogFormat {
+	case "":
+		cfg.LogFormat = "text"
+	case "text", "json":
+	default:
+		return nil, fmt.Errorf("log_format must be text or json, got %q", cfg.LogFormat)
+	}
+	names := make(map[string]bool)
 	for i := range cfg.Workspaces {
+		if names[cfg.Workspaces[i].Name] {
+			return nil, fmt.Errorf("workspace %d: duplicate name %q", i, cfg.Workspaces[i].Name)
+		}
+		names[cfg.Workspaces[i].Name] = true
 		if cfg.Workspaces[i].DefaultCLI == "" {
 			cfg.Workspaces[i].DefaultCLI = "claude"
 		}
+		if len(cfg.Workspaces[i].AllowedCLIs) == 0 {
+			cfg.Workspaces[i].AllowedCLIs = []string{"claude", "opencode"}
+		}
+		if !slices.Contains(cfg.Workspaces[i].AllowedCLIs, cfg.Workspaces[i].DefaultCLI) {
+			return nil, fmt.Errorf("workspace %d: default_cli %q is not in allowed_clis", i, cfg.Workspaces[i].DefaultCLI)
+		}
+		// Chats start with default_cli and default_model, which /cli and
+		// /model can only change within the allowlists
+		if model := cfg.Workspaces[i].Model; model != "" {
+			if cfg.Workspaces[i].DefaultModel != "" && cfg.Workspaces[i].DefaultModel != model {
+				return nil, fmt.Errorf("workspace %d: model %q and default_model %q differ, set only one", i, model, cfg.Workspaces[i].DefaultModel)
+			}
+			cfg.Workspaces[i].DefaultModel = model
+		}
+		if model := cfg.Workspaces[i].DefaultModel; model != "" && len(cfg.Workspaces[i].AllowedModels) > 0 && !slices.Contains(cfg.Workspaces[i].AllowedModels, model) {
+			return nil, fmt.Errorf("workspace %d: default_model %q is not in allowed_models", i, model)
+		}
 		if cfg.Workspaces[i].CommandTimeout == 0 {
-			cfg.Workspaces[i].CommandTimeout = 20 * time.Minute
+			cfg.Workspaces[i].CommandTimeout = 5 * time.Minute
+		}
+		if cfg.Workspaces[i].MaxDocumentBytes == 0 {
+			cfg.Workspaces[i].MaxDocumentBytes = 1 << 20 // 1MB
+		}
+		if cfg.Workspaces[i].MaxAudioBytes == 0 {
+			cfg.Workspaces[i].MaxAudioBytes = 20 << 20 // 20MB, the most bots can download
+		}
+		if cfg.Workspaces[i].MaxVideoBytes == 0 {
+			cfg.Workspaces[i].MaxVideoBytes = 20 << 20 // 20MB, the most bots can download
+		}
+		if cfg.Workspaces[i].QueueSize == 0 {
+			cfg.Workspaces[i].QueueSize = 3
+		}
+		if cfg.Workspaces[i].SendRetries == 0 {
+			cfg.Workspaces[i].SendRetries = 3
+		}
+		if cfg.Workspaces[i].MaxConcurrent == 0 {
+			cfg.Workspaces[i].MaxConcurrent = 2
+		}
+		if cfg.Workspaces[i].RateLimit > 0 && cfg.Workspaces[i].RateLimitWindow == 0 {
+			cfg.Workspaces[i].RateLimitWindow = time.Minute
+		}
+		if cfg.Workspaces[i].CollectWindow == 0 {
+			cfg.Workspaces[i].CollectWindow = 2 * time.Second
+		}
+		if cfg.Workspaces[i].InlineTimeout == 0 {
+			cfg.Workspaces[i].InlineTimeout = 8 * time.Second
+		}
+		if cfg.Workspaces[i].CacheTTL > 0 && cfg.Workspaces[i].CacheMaxEntries == 0 {
+			cfg.Workspaces[i].CacheMaxEntries = 100
+		}
+		if cfg.Workspaces[i].MaxAttachBytes == 0 {
+			cfg.Workspaces[i].MaxAttachBytes = 10 << 20 // 10MB
+		}
+		if cfg.Workspaces[i].MaxPromptBytes == 0 {
+			cfg.Workspaces[i].MaxPromptBytes = 100000
+		}
+		if cfg.Workspaces[i].CLIPrefix == "" {
+			cfg.Workspaces[i].CLIPrefix = "!"
+		}
+		if cfg.Workspaces[i].HistorySize == 0 {
+			cfg.Workspaces[i].HistorySize = 20
+		}
+		if cfg.Workspaces[i].TempDir == "" {
+			cfg.Workspaces[i].TempDir = os.TempDir()
 		}
 		if cfg.Workspaces[i].WorkingDir == "" {
 			return nil, fmt.Errorf("workspace %d: working_dir is required", i)
 		}
-		if cfg.Workspaces[i].BotToken == "" {
-			return nil, fmt.Errorf("workspace %d: bot_token is required", i)
+		if cfg.Workspaces[i].AllowedRoot == "" {
+			cfg.Workspaces[i].AllowedRoot = cfg.Workspaces[i].WorkingDir
+		}
+		token, err := resolveBotToken(cfg.Workspaces[i], filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("workspace %d: %w", i, err)
 		}
+		cfg.Workspaces[i].BotToken = token
 	}
 
 	return &cfg, nil
 }
 
+// resolveBotToken returns the workspace's bot token, read from bot_token_file,
+// bot_token_env or bot_token, in that order. A relative bot_token_file is
+// relative to configDir.
+func resolveBotToken(ws WorkspaceConfig, configDir string) (string, error) {
+	if ws.BotTokenFile != "" {
+		file := ws.BotTokenFile
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(configDir, file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to read bot_token_file: %w", err)
+		}
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	}
+	if ws.BotTokenEnv != "" {
+		if token := strings.TrimSpace(os.Getenv(ws.BotTokenEnv)); token != "" {
+			return token, nil
+		}
+	}
+	if ws.BotToken != "" {
+		return ws.BotToken, nil
+	}
+
+	switch {
+	case ws.BotTokenFile != "":
+		return "", fmt.Errorf("bot_token_file %s is empty", ws.BotTokenFile)
+	case ws.BotTokenEnv != "":
+		return "", fmt.Errorf("environment variable %s from bot_token_env is not set", ws.BotTokenEnv)
+	default:
+		return "", fmt.Errorf("bot_token, bot_token_file or bot_token_env is required")
+	}
+}
+
 // GetDefaultConfigPath returns the default configuration file path
 func GetDefaultConfigPath() string {
 	// Check for config in home directory
@@ -83,16 +334,89 @@ func GetDefaultConfigPath() string {
 func CreateExampleConfig(path string) error {
 	example := `# Telecode Multi-Bot Configuration
 # Each workspace represents a separate project with its own bot
+# Workspaces may also share a bot token; chats then switch with /workspace <name>
+
+# shutdown_timeout: 30s  # Optional: how long in-flight commands may finish on shutdown
+# mode: webhook  # Optional: receive updates via webhook instead of long polling (defaults to polling)
+# webhook_url: https://bot.example.com  # Required in webhook mode: public URL forwarded to webhook_addr
+# webhook_addr: ":8443"  # Required in webhook mode: local address of the webhook server
+# webhook_secret: "change-me"  # Optional: secret token checked on webhook requests (defaults to a hash of the bot token)
+# health_addr: ":8080"  # Optional: serve /healthz and /readyz on this address
+# metrics_addr: ":9090"  # Optional: serve Prometheus metrics on this address under /metrics
+# log_format: json  # Optional: format of command execution logs, text or json (defaults to text)
 
 workspaces:
   - name: project-a
     working_dir: /home/user/project-a
+    # allowed_root: /home/user  # Optional: directory /cd may switch within (defaults to working_dir)
     bot_token: "YOUR_BOT_TOKEN_1"
+    # bot_token_file: /etc/telecode/project-a.token  # Optional: read the token from this file instead of bot_token
+    # bot_token_env: PROJECT_A_BOT_TOKEN  # Optional: read the token from this environment variable instead of bot_token
     allowed_chats:
       - 123456789
+    # allowed_users:  # Optional: restrict to these Telegram user IDs (empty allows everyone in allowed chats)
+    #   - 123456789
+    # admin_users: [123456789]  # Optional: users allowed to run admin commands such as /env
     default_cli: opencode
+    # allowed_clis: [claude, opencode, aider, gemini]  # Optional: CLIs this workspace may run (defaults to claude and opencode)
+    # gemini_binary: /usr/local/bin/gemini  # Optional: Gemini CLI executable (defaults to gemini in PATH)
     command_timeout: 20m
-    # model: anthropic/opus-4.6  # Optional: OpenCode model (defaults to opus-4.6)
+    # default_model: anthropic/opus-4.6  # Optional: model chats and new sessions start with (defaults to the CLI's own), also accepted as model
+    # allowed_models: [anthropic/opus-4.6, anthropic/sonnet-4.5]  # Optional: models selectable with /model (empty allows any, must include default_model)
+    # system_prompt: "You are working on the project-a web frontend. Prefer TypeScript."  # Optional: context added to every prompt
+    # env:  # Optional: environment variables for CLI runs, e.g. per-project API keys (never logged)
+    #   ANTHROPIC_API_KEY: "sk-ant-..."
+    #   ANTHROPIC_BASE_URL: https://llm-proxy.example.com
+    # extra_args:  # Optional: extra flags appended to each CLI's command
+    #   claude: ["--max-turns", "20"]
+    #   opencode: ["--agent", "build"]
+    # session_file: /home/user/.telecode/sessions/project-a.json  # Optional: persist sessions across restarts
+    # max_concurrent: 2  # Optional: CLI processes allowed to run at once in this workspace (defaults to 2)
+    # queue_size: 3  # Optional: prompts that may wait while a command runs (defaults to 3)
+    # rate_limit: 10  # Optional: prompts each user may send per rate_limit_window (commands are exempt)
+    # rate_limit_window: 5m  # Optional: window for rate_limit (defaults to 1m)
+    # confirm_reset: true  # Optional: ask for confirmation before /new or /clear discards a session
+    # max_output_chunks: 10  # Optional: messages one answer may span, the full output is attached as output.txt beyond that (defaults to no limit)
+    # file_threshold_bytes: 16384  # Optional: send longer answers as a response.txt document instead of messages (defaults to never)
+    # reply_context: true  # Optional: when replying to a message, include its text in the prompt
+    # forward_context: true  # Optional: name the original sender of forwarded messages in the prompt
+    # cost_per_input_token: 0.000003  # Optional: dollars per input token, for the cost estimate in /stats
+    # cost_per_output_token: 0.000015  # Optional: dollars per output token
+    # collect_mode: true  # Optional: combine messages sent in quick succession into one prompt, chats toggle it with /collect
+    # collect_window: 2s  # Optional: how long to wait for further messages in collect mode (defaults to 2s)
+    # show_tool_trace: true  # Optional: after each answer, summarize the tools Claude Code used ("🔧 Read 3 files, ran 2 commands")
+    # disable_typing_action: true  # Optional: don't show the typing indicator while a command runs
+    # inline_queries: true  # Optional: answer "@yourbot question" in any chat with a one-off run (enable inline mode in @BotFather)
+    # inline_timeout: 8s  # Optional: time limit for inline query runs (defaults to 8s)
+    # disable_ansi_strip: true  # Optional: send CLI output with terminal escape codes (colors) left in
+    # history_size: 20  # Optional: prompts remembered per chat for /history (defaults to 20)
+    # temp_dir: /var/tmp/telecode  # Optional: where downloaded files are stored (defaults to the system temp dir)
+    # send_retries: 3  # Optional: retries for failed Telegram sends (defaults to 3)
+    # default_image_prompt: "Describe the UI bug shown"  # Optional: prompt for photos sent without caption
+    # default_document_prompt: "Review this file."  # Optional: prompt for documents sent without caption
+    # max_image_bytes: 1048576  # Optional: download the largest photo size under this limit (defaults to no limit)
+    # max_document_bytes: 1048576  # Optional: max size of uploaded documents (defaults to 1MB)
+    # max_audio_duration: 30m  # Optional: longer audio files aren't transcribed (defaults to no limit)
+    # max_audio_bytes: 20971520  # Optional: max size of audio files to transcribe (defaults to 20MB)
+    # ffmpeg_binary: ffmpeg  # Optional: enable videos, sent to the CLI as a frame and the transcript of their audio
+    # max_video_duration: 10m  # Optional: longer videos are rejected (defaults to no limit)
+    # max_video_bytes: 20971520  # Optional: max size of videos (defaults to 20MB)
+    # max_prompt_bytes: 100000  # Optional: longer prompts are rejected (defaults to 100000)
+    # cli_prefix: "@"  # Optional: run one prompt with another CLI, as in "@opencode fix the tests" (defaults to "!")
+    # session_idle_timeout: 12h  # Optional: start a new session after this long without prompts (defaults to never)
+    # cache_ttl: 10m  # Optional: answer repeated prompts from a cache for this long (defaults to no caching)
+    # cache_max_entries: 100  # Optional: replies kept in the cache (defaults to 100)
+    # attach_changed_files: true  # Optional: list the files a run created or modified, to fetch with /files
+    # max_attach_bytes: 10485760  # Optional: max size of files sent by /files (defaults to 10MB)
+    # welcome_message: "Hi! Ask me anything about the frontend."  # Optional: greeting on a new chat's first /start
+    # enabled_commands: ["/commit"]  # Optional: turn on commands that are off by default (/commit, /compare)
+    # disabled_commands: ["/cd", "/env"]  # Optional: turn off built-in commands
+    # allow_compare: true  # Optional: enable /compare to run a prompt with both claude and opencode
+    # allow_git: true  # Optional: enable /commit to commit all changes of the working directory
+    # thinking_animation: true  # Optional: animate the placeholder shown until output arrives
+    # thinking_frames: ["⏳ Working", "⌛ Working"]  # Optional: placeholder frames (defaults to "🤔 Thinking." .. "🤔 Thinking...")
+    # transcribe_command: ["whisper-cli", "-m", "/opt/whisper/ggml-base.bin", "-nt", "-f", "{file}"]  # Optional: enable voice messages
+    # post_process: ["sed", "-E", "s/sk-[A-Za-z0-9_-]+/sk-***/g"]  # Optional: filter CLI output through this command (stdin to stdout) before sending
 
   - name: project-b
     working_dir: /home/user/project-b
@@ -100,7 +424,7 @@ workspaces:
     allowed_chats:
       - 987654321
     default_cli: claude
-    # command_timeout defaults to 20m if not specified
+    # command_timeout defaults to 5m if not specified
 `
 	return os.WriteFile(path, []byte(example), 0644)
 }
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadWorkspace loads a config holding one workspace with the given extra
+// YAML lines
+func loadWorkspace(t *testing.T, extra string) (*WorkspaceConfig, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "telecode.yml")
+	data := "workspaces:\n  - name: test\n    working_dir: /tmp\n    bot_token: \"123:abc\"\n" + extra
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg.Workspaces[0], nil
+}
+
+func TestLoadConfigModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		extra   string
+		want    string
+		wantErr string
+	}{
+		{name: "default_model", extra: "    default_model: sonnet\n", want: "sonnet"},
+		{name: "model", extra: "    model: sonnet\n", want: "sonnet"},
+		{name: "both agree", extra: "    model: opus\n    default_model: opus\n", want: "opus"},
+		{
+			name:    "both differ",
+			extra:   "    model: opus\n    default_model: sonnet\n",
+			wantErr: `model "opus" and default_model "sonnet" differ`,
+		},
+		{name: "allowed", extra: "    default_model: opus\n    allowed_models: [opus, sonnet]\n", want: "opus"},
+		{
+			name:    "not allowed",
+			extra:   "    model: haiku\n    allowed_models: [opus, sonnet]\n",
+			wantErr: `default_model "haiku" is not in allowed_models`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, err := loadWorkspace(t, tt.extra)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadConfig error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ws.DefaultModel != tt.want {
+				t.Errorf("default_model = %q, want %q", ws.DefaultModel, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigCommandTimeout(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra string
+		want  time.Duration
+	}{
+		{name: "default", want: 5 * time.Minute},
+		{name: "configured", extra: "    command_timeout: 30m\n", want: 30 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, err := loadWorkspace(t, tt.extra)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ws.CommandTimeout != tt.want {
+				t.Errorf("command_timeout = %s, want %s", ws.CommandTimeout, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	Register("aider", func() Executor { return &AiderExecutor{} })
+}
+
+// AiderExecutor implements Executor for the aider CLI. Aider keeps no
+// session between runs, every message starts from the repository state.
+type AiderExecutor struct{}
+
+// BuildCommand builds the aider command
+func (e *AiderExecutor) BuildCommand(req Request) []string {
+	// Aider has no system prompt flag, prepend it to the prompt instead
+	prompt := req.Prompt
+	if req.SystemPrompt != "" {
+		prompt = req.SystemPrompt + "\n\n" + prompt
+	}
+
+	// --no-pretty keeps the output free of colors and markdown rendering. The
+	// "=" form keeps a prompt starting with "-" from being read as a flag.
+	cmd := []string{"aider", "--message=" + prompt, "--yes", "--no-pretty"}
+
+	if req.Model != "" {
+		cmd = append(cmd, "--model="+req.Model)
+	}
+
+	cmd = append(cmd, req.ExtraArgs...)
+
+	// Files given as arguments are added to the chat
+	if len(req.FilePaths) > 0 {
+		cmd = append(cmd, "--")
+		cmd = append(cmd, req.FilePaths...)
+	}
+
+	return cmd
+}
+
+// ManagedFlags returns the flags set by BuildCommand
+func (e *AiderExecutor) ManagedFlags() []string {
+	return []string{"--message", "-m", "--message-file", "-f", "--yes", "--yes-always", "--no-pretty", "--pretty", "--model"}
+}
+
+// SessionParser returns nil, aider has no sessions
+func (e *AiderExecutor) SessionParser() SessionParser {
+	return nil
+}
+
+// aiderBannerPrefixes start the lines aider prints before the answer
+var aiderBannerPrefixes = []string{
+	"Aider v", "Main model:", "Model:", "Weak model:", "Editor model:",
+	"Git repo:", "Repo-map:", "Added ", "Use /help", "Warning:",
+}
+
+// ExtractText returns aider's answer without the startup banner and the
+// token usage reports
+func (e *AiderExecutor) ExtractText(output string) (text string, ok bool) {
+	lines := strings.Split(output, "\n")
+
+	// Skip the banner up to the first line that isn't part of it
+	start := 0
+	for start < len(lines) {
+		line := strings.TrimSpace(lines[start])
+		if line != "" && !hasAnyPrefix(line, aiderBannerPrefixes) {
+			break
+		}
+		start++
+	}
+
+	var kept []string
+	for _, line := range lines[start:] {
+		if strings.HasPrefix(strings.TrimSpace(line), "Tokens:") {
+			continue
+		}
+		kept = append(kept, line)
+	}
+
+	text = strings.TrimSpace(strings.Join(kept, "\n"))
+	return text, text != ""
+}
+
+// hasAnyPrefix reports whether s starts with one of prefixes
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Binary returns the executable the CLI runs
+func (e *AiderExecutor) Binary() string {
+	return "aider"
+}
+
+// Name returns the Executor name
+func (e *AiderExecutor) Name() string {
+	return "aider"
+}
+
+// Stats returns statistics information
+func (e *AiderExecutor) Stats() (string, error) {
+	// Aider reports usage per message only, just check installation
+	_, err := exec.LookPath("aider")
+	if err != nil {
+		return "aider is not installed", nil
+	}
+	return "aider is installed (token usage is not tracked across messages)", nil
+}
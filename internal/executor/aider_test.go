@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"slices"
+	"testing"
+)
+
+// aiderOutput is output captured from aider --message ... --yes --no-pretty
+const aiderOutput = `Aider v0.86.1
+Main model: anthropic/claude-sonnet-4-5 with diff edit format, infinite output
+Weak model: anthropic/claude-3-5-haiku-20241022
+Git repo: .git with 42 files
+Repo-map: using 4096 tokens, auto refresh
+Added internal/util.go to the chat.
+
+The off-by-one is in the loop bound, it should stop at len(items).
+
+internal/util.go
+<<<<<<< SEARCH
+	for i := 0; i <= len(items); i++ {
+=======
+	for i := 0; i < len(items); i++ {
+>>>>>>> REPLACE
+
+Tokens: 2.1k sent, 95 received. Cost: $0.0077 message, $0.0077 session.
+Applied edit to internal/util.go
+Commit 3f2a9c1 fix: Stop loop at the last item
+`
+
+func TestAiderExtractText(t *testing.T) {
+	want := `The off-by-one is in the loop bound, it should stop at len(items).
+
+internal/util.go
+<<<<<<< SEARCH
+	for i := 0; i <= len(items); i++ {
+=======
+	for i := 0; i < len(items); i++ {
+>>>>>>> REPLACE
+
+Applied edit to internal/util.go
+Commit 3f2a9c1 fix: Stop loop at the last item`
+
+	text, ok := (&AiderExecutor{}).ExtractText(aiderOutput)
+	if !ok || text != want {
+		t.Errorf("ExtractText = %q, %v, want %q, true", text, ok, want)
+	}
+
+	if _, ok := (&AiderExecutor{}).ExtractText("Aider v0.86.1\nMain model: gpt-5\n"); ok {
+		t.Error("ExtractText found an answer in the banner")
+	}
+}
+
+func TestAiderBuildCommand(t *testing.T) {
+	got := (&AiderExecutor{}).BuildCommand(Request{
+		Prompt:    "--help",
+		Model:     "sonnet",
+		FilePaths: []string{"/tmp/a.go"},
+	})
+	want := []string{"aider", "--message=--help", "--yes", "--no-pretty", "--model=sonnet", "--", "/tmp/a.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("BuildCommand = %q, want %q", got, want)
+	}
+
+	if parser := (&AiderExecutor{}).SessionParser(); parser != nil {
+		t.Error("aider has a session parser, but keeps no sessions")
+	}
+}
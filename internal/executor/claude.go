@@ -1,47 +1,223 @@
 package executor
 
 import (
+	"bufio"
+	"encoding/json"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 )
 
+func init() {
+	Register("claude", func() Executor { return &ClaudeExecutor{} })
+}
+
 // ClaudeExecutor implements Executor for Claude Code CLI
 type ClaudeExecutor struct{}
 
 // BuildCommand builds the Claude Code command
-func (e *ClaudeExecutor) BuildCommand(prompt, sessionID, imagePath string, model string) []string {
-	cmd := []string{"claude", "-p", prompt}
+func (e *ClaudeExecutor) BuildCommand(req Request) []string {
+	cmd := e.buildArgs(req, []string{"claude", "-p"})
+
+	// "--" keeps a prompt starting with "-" from being read as a flag. Claude
+	// Code appends file paths at the end of arguments.
+	cmd = append(cmd, "--", req.Prompt)
+	return append(cmd, req.FilePaths...)
+}
+
+// BuildStdinCommand builds the Claude Code command reading the prompt from
+// stdin. Attached files are listed after the prompt, since a positional
+// argument would be taken as the prompt.
+func (e *ClaudeExecutor) BuildStdinCommand(req Request) ([]string, string) {
+	stdin := req.Prompt
+	if len(req.FilePaths) > 0 {
+		stdin += "\n\n" + strings.Join(req.FilePaths, "\n")
+	}
+	return e.buildArgs(req, []string{"claude", "-p"}), stdin
+}
+
+// buildArgs appends the arguments other than the prompt and files to cmd
+func (e *ClaudeExecutor) buildArgs(req Request, cmd []string) []string {
+	// stream-json reports the session ID and lets the answer be streamed
+	cmd = append(cmd, "--output-format", "stream-json", "--verbose")
+
+	if req.SessionID != "" {
+		cmd = append(cmd, "--resume="+req.SessionID)
+	}
+
+	if req.Model != "" {
+		cmd = append(cmd, "--model="+req.Model)
+	}
+
+	if req.SystemPrompt != "" {
+		cmd = append(cmd, "--append-system-prompt="+req.SystemPrompt)
+	}
+
+	return append(cmd, req.ExtraArgs...)
+}
+
+// ManagedFlags returns the flags set by BuildCommand
+func (e *ClaudeExecutor) ManagedFlags() []string {
+	return []string{"-p", "--print", "--output-format", "--resume", "-r", "--continue", "-c", "--model", "--append-system-prompt"}
+}
+
+// claudeSessionParser reads the session ID from Claude Code's stream-json
+// events, e.g. {"type":"system","subtype":"init","session_id":"..."}
+var claudeSessionParser = JSONSessionParser{Path: "session_id"}
+
+// SessionParser returns the parser for Claude Code output
+func (e *ClaudeExecutor) SessionParser() SessionParser {
+	return claudeSessionParser
+}
+
+// claudeProjectDirRegex matches the characters Claude Code replaces with "-"
+// when naming the session directory of a project
+var claudeProjectDirRegex = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// FindSession returns the session Claude Code wrote for workDir since the
+// given time. Claude Code stores every session as <config dir>/projects/
+// <workDir with non-alphanumerics replaced by "-">/<session ID>.jsonl. If
+// several sessions were written, there is no telling which one is the run's,
+// so none is returned.
+func (e *ClaudeExecutor) FindSession(workDir string, since time.Time) (string, bool) {
+	configDir := os.Getenv("CLAUDE_CONFIG_DIR")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		configDir = filepath.Join(home, ".claude")
+	}
+
+	dir := filepath.Join(configDir, "projects", claudeProjectDirRegex.ReplaceAllString(workDir, "-"))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", false
+	}
 
-	if sessionID != "" {
-		cmd = append(cmd, "--resume", sessionID)
+	var sessionIDs []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		sessionIDs = append(sessionIDs, name)
 	}
+	if len(sessionIDs) != 1 {
+		return "", false
+	}
+	return sessionIDs[0], true
+}
+
+// claudeEvent is the subset of a Claude Code stream-json event we read
+type claudeEvent struct {
+	Type   string `json:"type"`
+	Result string `json:"result"`
+	Usage  *struct {
+		InputTokens              int `json:"input_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+	} `json:"usage"`
+	Message struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+			Name string `json:"name"` // Tool name of tool_use content
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// ExtractText extracts the answer from Claude Code stream-json output.
+// The final result event is preferred; for unfinished runs the assistant text
+// seen so far is returned. ok is false if the output holds no such events.
+func (e *ClaudeExecutor) ExtractText(output string) (text string, ok bool) {
+	var texts []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var event claudeEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			// Not a JSON line, skip
+			continue
+		}
 
-	if imagePath != "" {
-		// Claude Code appends file path at the end of arguments
-		cmd = append(cmd, imagePath)
+		switch event.Type {
+		case "result":
+			if event.Result != "" {
+				return event.Result, true
+			}
+		case "assistant":
+			for _, content := range event.Message.Content {
+				if content.Type == "text" && content.Text != "" {
+					texts = append(texts, content.Text)
+				}
+			}
+		}
 	}
 
-	return cmd
+	return strings.Join(texts, "\n\n"), len(texts) > 0
 }
 
-// ParseSessionID extracts session ID from Claude Code output
-func (e *ClaudeExecutor) ParseSessionID(output string) string {
-	// Try various session ID formats
-	patterns := []string{
-		`session[:\s]+([a-zA-Z0-9_-]+)`,
-		`session\s*id[:\s]+([a-zA-Z0-9_-]+)`,
-		`resuming\s+session[:\s]+([a-zA-Z0-9_-]+)`,
+// ParseUsage reads the token usage from the result event. Cached input
+// tokens count as input.
+func (e *ClaudeExecutor) ParseUsage(output string) (Usage, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var event claudeEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.Type == "result" && event.Usage != nil {
+			u := event.Usage
+			return Usage{
+				InputTokens:  u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens,
+				OutputTokens: u.OutputTokens,
+			}, true
+		}
 	}
+	return Usage{}, false
+}
+
+// ToolCalls returns the tools used in assistant messages, e.g.
+// {"type":"tool_use","name":"Read",...}
+func (e *ClaudeExecutor) ToolCalls(output string) []string {
+	var tools []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
 
-	for _, pattern := range patterns {
-		re := regexp.MustCompile(pattern)
-		match := re.FindStringSubmatch(output)
-		if len(match) > 1 {
-			return match[1]
+	for scanner.Scan() {
+		var event claudeEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.Type != "assistant" {
+			continue
+		}
+		for _, content := range event.Message.Content {
+			if content.Type == "tool_use" && content.Name != "" {
+				tools = append(tools, content.Name)
+			}
 		}
 	}
+	return tools
+}
 
-	return ""
+// Binary returns the executable the CLI runs
+func (e *ClaudeExecutor) Binary() string {
+	return "claude"
 }
 
 // Name returns the Executor name
@@ -1,12 +1,42 @@
 package executor
 
+import "strings"
+
+// Request holds everything a CLI invocation is built from
+type Request struct {
+	Prompt    string
+	SessionID string // Session to resume, empty for a new one
+	// FilePaths are images or documents attached to the prompt
+	FilePaths []string
+	Model     string // Empty uses the CLI's default
+	// SystemPrompt is added to the CLI's system prompt when supported,
+	// otherwise prepended to the prompt. Empty adds nothing.
+	SystemPrompt string
+	// ExtraArgs are appended after the standard arguments
+	ExtraArgs []string
+}
+
 // Executor defines the interface for CLI executors
 type Executor interface {
-	// BuildCommand builds the CLI command
-	BuildCommand(prompt string, sessionID string, imagePath string, model string) []string
+	// BuildCommand builds the CLI command. It is run directly, never through
+	// a shell, and the prompt and other user-controlled values must not be
+	// parsable as flags: they go after "--" or in "--flag=value" form.
+	BuildCommand(req Request) []string
+
+	// ManagedFlags returns the flags set by BuildCommand, which extra
+	// arguments should not override
+	ManagedFlags() []string
+
+	// SessionParser returns the parser for session IDs in the CLI's output,
+	// or nil if the CLI keeps no sessions
+	SessionParser() SessionParser
 
-	// ParseSessionID extracts session ID from output
-	ParseSessionID(output string) string
+	// ExtractText returns the response text found in the CLI's raw output.
+	// ok is false if the output holds no response, e.g. for unfinished runs.
+	ExtractText(output string) (text string, ok bool)
+
+	// Binary returns the executable the CLI runs
+	Binary() string
 
 	// Name returns the CLI name
 	Name() string
@@ -14,3 +44,58 @@ type Executor interface {
 	// Stats returns statistics information
 	Stats() (string, error)
 }
+
+// Usage is the token usage a CLI reported for a run
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// UsageParser is implemented by executors whose CLI reports token usage
+type UsageParser interface {
+	// ParseUsage returns the usage found in the CLI's output, or false if
+	// none was reported
+	ParseUsage(output string) (Usage, bool)
+}
+
+// StdinPrompter is implemented by executors whose CLI can read the prompt
+// from stdin, which avoids the argument size limit for long prompts
+type StdinPrompter interface {
+	// BuildStdinCommand builds the CLI command like BuildCommand, but returns
+	// the prompt to write to the CLI's stdin instead of passing it as an
+	// argument
+	BuildStdinCommand(req Request) (cmd []string, stdin string)
+}
+
+// ToolTracer is implemented by executors whose CLI reports the tools the
+// agent used
+type ToolTracer interface {
+	// ToolCalls returns the names of the tools called, in order
+	ToolCalls(output string) []string
+}
+
+// BinaryConfigurer is implemented by executors whose executable can be
+// configured, e.g. with gemini_binary
+type BinaryConfigurer interface {
+	// SetBinary makes the executor run the executable at path instead of
+	// the default one
+	SetBinary(path string)
+}
+
+// CollidingArgs returns the args that set one of the flags managed by e,
+// either as "--flag" or "--flag=value"
+func CollidingArgs(e Executor, args []string) []string {
+	managed := make(map[string]bool)
+	for _, flag := range e.ManagedFlags() {
+		managed[flag] = true
+	}
+
+	var colliding []string
+	for _, arg := range args {
+		flag, _, _ := strings.Cut(arg, "=")
+		if managed[flag] {
+			colliding = append(colliding, arg)
+		}
+	}
+	return colliding
+}
@@ -0,0 +1,171 @@
+package executor
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestBuildCommand pins the commands claude and opencode are run with, which
+// must not change when executors are refactored
+func TestBuildCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		cli  string
+		req  Request
+		want []string
+	}{
+		{
+			name: "claude new session",
+			cli:  "claude",
+			req:  Request{Prompt: "fix the tests"},
+			want: []string{"claude", "-p", "--output-format", "stream-json", "--verbose", "--", "fix the tests"},
+		},
+		{
+			name: "claude resumed",
+			cli:  "claude",
+			req: Request{
+				Prompt:       "and now?",
+				SessionID:    "abc",
+				Model:        "sonnet",
+				SystemPrompt: "Be brief.",
+				FilePaths:    []string{"/tmp/a.png"},
+				ExtraArgs:    []string{"--max-turns", "3"},
+			},
+			want: []string{"claude", "-p", "--output-format", "stream-json", "--verbose", "--resume=abc", "--model=sonnet",
+				"--append-system-prompt=Be brief.", "--max-turns", "3", "--", "and now?", "/tmp/a.png"},
+		},
+		{
+			name: "opencode new session",
+			cli:  "opencode",
+			req:  Request{Prompt: "fix the tests"},
+			want: []string{"opencode", "run", "--format", "json", "--model=anthropic/opus-4.6", "--", "fix the tests"},
+		},
+		{
+			name: "opencode resumed",
+			cli:  "opencode",
+			req: Request{
+				Prompt:       "and now?",
+				SessionID:    "ses_1",
+				Model:        "openai/gpt-5",
+				SystemPrompt: "Be brief.",
+				FilePaths:    []string{"/tmp/a.png", "/tmp/b.txt"},
+				ExtraArgs:    []string{"--agent", "build"},
+			},
+			want: []string{"opencode", "run", "--format", "json", "--model=openai/gpt-5", "--session=ses_1",
+				"--file=/tmp/a.png", "--file=/tmp/b.txt", "--agent", "build", "--", "Be brief.\n\nand now?"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, ok := Lookup(tt.cli)
+			if !ok {
+				t.Fatalf("%s is not registered", tt.cli)
+			}
+			if got := exec.BuildCommand(tt.req); !slices.Equal(got, tt.want) {
+				t.Errorf("BuildCommand = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	tests := []struct {
+		name   string
+		cli    string
+		output string
+		want   string
+		wantOK bool
+	}{
+		{name: "claude", cli: "claude", output: claudeOutput, want: "The tests pass.", wantOK: true},
+		{name: "claude no events", cli: "claude", output: "command not found\n"},
+		{name: "opencode", cli: "opencode", output: openCodeOutput, want: "The tests pass.", wantOK: true},
+		{name: "opencode no events", cli: "opencode", output: "command not found\n"},
+		{
+			name:   "opencode long line",
+			cli:    "opencode",
+			output: `{"type":"text","part":{"text":"` + strings.Repeat("x", 1024*1024) + `"}}`,
+			want:   strings.Repeat("x", 1024*1024),
+			wantOK: true,
+		},
+		{
+			name:   "opencode oversized line",
+			cli:    "opencode",
+			output: `{"type":"text","part":{"text":"a"}}` + "\n" + strings.Repeat("x", 17*1024*1024),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, _ := Lookup(tt.cli)
+			got, ok := exec.ExtractText(tt.output)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ExtractText = %q, %v, want %q, %v", truncate(got), ok, truncate(tt.want), tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSetBinary(t *testing.T) {
+	exec, _ := Lookup("gemini")
+	configurer, ok := exec.(BinaryConfigurer)
+	if !ok {
+		t.Fatal("gemini executor can't be configured with a binary")
+	}
+	configurer.SetBinary("/opt/gemini/bin/gemini")
+
+	if got := exec.Binary(); got != "/opt/gemini/bin/gemini" {
+		t.Errorf("Binary = %q, want the configured one", got)
+	}
+	if got := exec.BuildCommand(Request{Prompt: "hi"})[0]; got != "/opt/gemini/bin/gemini" {
+		t.Errorf("command runs %q, want the configured binary", got)
+	}
+
+	// Every workspace gets its own executor
+	if other, _ := Lookup("gemini"); other.Binary() != "gemini" {
+		t.Errorf("binary of a new executor = %q, want gemini", other.Binary())
+	}
+}
+
+// truncate shortens s for error messages
+func truncate(s string) string {
+	if len(s) > 40 {
+		return s[:40] + "…"
+	}
+	return s
+}
+
+// injectionPrompts could be taken as flags or shell syntax if not passed as
+// a single literal argument
+var injectionPrompts = []string{"--help", "-m gpt-4", "; rm -rf /", "$(touch pwned) `id` | cat"}
+
+func TestBuildCommandPromptIsLiteral(t *testing.T) {
+	for _, name := range Names() {
+		for _, prompt := range injectionPrompts {
+			t.Run(name+" "+prompt, func(t *testing.T) {
+				exec, _ := Lookup(name)
+				cmd := exec.BuildCommand(Request{Prompt: prompt})
+
+				found := false
+				for i, arg := range cmd {
+					switch {
+					case arg == prompt:
+						// A bare prompt must follow the end of the flags
+						if !slices.Contains(cmd[:i], "--") {
+							t.Errorf("prompt %q is not after \"--\" in %q", prompt, cmd)
+						}
+						found = true
+					case strings.HasPrefix(arg, "--") && strings.HasSuffix(arg, "="+prompt):
+						found = true // "--flag=value" form
+					case strings.Contains(arg, prompt):
+						t.Errorf("prompt %q is embedded in argument %q", prompt, arg)
+					}
+				}
+				if !found {
+					t.Errorf("prompt %q is not a single argument of %q", prompt, cmd)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package executor
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+)
+
+func init() {
+	Register("gemini", func() Executor { return &GeminiExecutor{} })
+}
+
+// GeminiExecutor implements Executor for Google's Gemini CLI. Like aider it
+// keeps no session between runs.
+type GeminiExecutor struct {
+	// binary is the gemini executable, "gemini" if empty
+	binary string
+}
+
+// BuildCommand builds the Gemini CLI command
+func (e *GeminiExecutor) BuildCommand(req Request) []string {
+	// The "=" form keeps a prompt starting with "-" from being read as a flag
+	return e.buildArgs(req, []string{e.Binary(), "--prompt=" + geminiPrompt(req)})
+}
+
+// BuildStdinCommand builds the Gemini CLI command reading the prompt from
+// stdin, which runs it non-interactively like --prompt
+func (e *GeminiExecutor) BuildStdinCommand(req Request) ([]string, string) {
+	return e.buildArgs(req, []string{e.Binary()}), geminiPrompt(req)
+}
+
+// geminiPrompt returns the prompt sent to Gemini, with the system prompt and
+// the attached files
+func geminiPrompt(req Request) string {
+	// Gemini has no system prompt flag, prepend it to the prompt instead
+	prompt := req.Prompt
+	if req.SystemPrompt != "" {
+		prompt = req.SystemPrompt + "\n\n" + prompt
+	}
+
+	// Files are referenced with @path in the prompt; images are read by the
+	// model directly
+	for _, filePath := range req.FilePaths {
+		prompt += "\n@" + filePath
+	}
+	return prompt
+}
+
+// buildArgs appends the arguments other than the prompt to cmd
+func (e *GeminiExecutor) buildArgs(req Request, cmd []string) []string {
+	cmd = append(cmd, "--output-format", "json")
+
+	if req.Model != "" {
+		cmd = append(cmd, "--model="+req.Model)
+	}
+
+	cmd = append(cmd, req.ExtraArgs...)
+
+	return cmd
+}
+
+// ManagedFlags returns the flags set by BuildCommand
+func (e *GeminiExecutor) ManagedFlags() []string {
+	return []string{"--prompt", "-p", "--prompt-interactive", "-i", "--output-format", "-o", "--model", "-m"}
+}
+
+// SessionParser returns nil, runs of the Gemini CLI are independent
+func (e *GeminiExecutor) SessionParser() SessionParser {
+	return nil
+}
+
+// ExtractText returns the response from the Gemini CLI's JSON output, e.g.
+// {"response":"...","stats":{...}}
+func (e *GeminiExecutor) ExtractText(output string) (text string, ok bool) {
+	var result struct {
+		Response string `json:"response"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(output)), &result); err != nil {
+		return "", false
+	}
+	return result.Response, result.Response != ""
+}
+
+// Binary returns the executable the CLI runs
+func (e *GeminiExecutor) Binary() string {
+	if e.binary != "" {
+		return e.binary
+	}
+	return "gemini"
+}
+
+// SetBinary makes the executor run the gemini executable at path
+func (e *GeminiExecutor) SetBinary(path string) {
+	e.binary = path
+}
+
+// Name returns the Executor name
+func (e *GeminiExecutor) Name() string {
+	return "gemini"
+}
+
+// Stats returns statistics information
+func (e *GeminiExecutor) Stats() (string, error) {
+	// Gemini CLI reports usage per run only, just check installation
+	_, err := exec.LookPath(e.Binary())
+	if err != nil {
+		return "Gemini CLI is not installed", nil
+	}
+	return "Gemini CLI is installed", nil
+}
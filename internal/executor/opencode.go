@@ -1,42 +1,153 @@
 package executor
 
 import (
+	"bufio"
+	"encoding/json"
 	"os/exec"
 	"regexp"
+	"strings"
 )
 
+func init() {
+	Register("opencode", func() Executor { return &OpenCodeExecutor{} })
+}
+
 // OpenCodeExecutor implements Executor for OpenCode CLI
 type OpenCodeExecutor struct{}
 
 // BuildCommand builds the OpenCode command
-func (e *OpenCodeExecutor) BuildCommand(prompt, sessionID, imagePath string, model string) []string {
+func (e *OpenCodeExecutor) BuildCommand(req Request) []string {
+	return e.buildArgs(req, openCodePrompt(req))
+}
+
+// BuildStdinCommand builds the OpenCode command reading the prompt from
+// stdin, which opencode run appends to its (empty) message
+func (e *OpenCodeExecutor) BuildStdinCommand(req Request) ([]string, string) {
+	return e.buildArgs(req, ""), openCodePrompt(req)
+}
+
+// openCodePrompt returns the prompt sent to OpenCode. OpenCode has no system
+// prompt flag, so the system prompt is prepended to it instead.
+func openCodePrompt(req Request) string {
+	if req.SystemPrompt != "" {
+		return req.SystemPrompt + "\n\n" + req.Prompt
+	}
+	return req.Prompt
+}
+
+// buildArgs builds the OpenCode command, passing prompt as the message
+// unless it is empty
+func (e *OpenCodeExecutor) buildArgs(req Request, prompt string) []string {
 	// Use default model if not specified
+	model := req.Model
 	if model == "" {
 		model = "anthropic/opus-4.6"
 	}
-	cmd := []string{"opencode", "run", "--format", "json", "--model", model, prompt}
 
-	if sessionID != "" {
-		cmd = append(cmd, "--session", sessionID)
+	cmd := []string{"opencode", "run", "--format", "json", "--model=" + model}
+
+	if req.SessionID != "" {
+		cmd = append(cmd, "--session="+req.SessionID)
+	}
+
+	for _, filePath := range req.FilePaths {
+		cmd = append(cmd, "--file="+filePath)
 	}
 
-	if imagePath != "" {
-		cmd = append(cmd, "--file", imagePath)
+	cmd = append(cmd, req.ExtraArgs...)
+
+	// "--" keeps a prompt starting with "-" from being read as a flag
+	if prompt != "" {
+		cmd = append(cmd, "--", prompt)
 	}
 
 	return cmd
 }
 
-// ParseSessionID extracts session ID from OpenCode JSON output
-func (e *OpenCodeExecutor) ParseSessionID(output string) string {
-	// Parse sessionID from JSON output (e.g., {"type":"step_start","sessionID":"ses_xxx",...})
-	// Look for "sessionID":"ses_..." pattern in the first JSON line
-	re := regexp.MustCompile(`"sessionID":"(ses_[a-zA-Z0-9_-]+)"`)
-	match := re.FindStringSubmatch(output)
-	if len(match) > 1 {
-		return match[1]
+// ManagedFlags returns the flags set by BuildCommand
+func (e *OpenCodeExecutor) ManagedFlags() []string {
+	return []string{"--format", "--model", "-m", "--session", "-s", "--continue", "-c", "--file", "-f"}
+}
+
+// openCodeSessionParser finds the session ID in OpenCode JSON events, e.g.
+// {"type":"step_start","sessionID":"ses_xxx",...}
+var openCodeSessionParser = RegexSessionParser{
+	Pattern: regexp.MustCompile(`"sessionID"\s*:\s*"(ses_[a-zA-Z0-9_-]+)"`),
+}
+
+// SessionParser returns the parser for OpenCode output
+func (e *OpenCodeExecutor) SessionParser() SessionParser {
+	return openCodeSessionParser
+}
+
+// ExtractText returns the text parts found in OpenCode JSON output. ok is
+// false if the output holds no text events or has a line too long to read.
+func (e *OpenCodeExecutor) ExtractText(output string) (text string, ok bool) {
+	var texts []string
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var event map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			// Not a JSON line, skip
+			continue
+		}
+
+		// Extract text from text events
+		if eventType, ok := event["type"].(string); ok && eventType == "text" {
+			if part, ok := event["part"].(map[string]interface{}); ok {
+				if text, ok := part["text"].(string); ok && text != "" {
+					texts = append(texts, text)
+				}
+			}
+		}
 	}
-	return ""
+	if scanner.Err() != nil {
+		return "", false // Texts after an oversized line would be missing
+	}
+
+	return strings.Join(texts, "\n\n"), len(texts) > 0
+}
+
+// ParseUsage sums the token usage of the step_finish events, e.g.
+// {"type":"step_finish","part":{"tokens":{"input":10,"output":5}}}
+func (e *OpenCodeExecutor) ParseUsage(output string) (Usage, bool) {
+	var usage Usage
+	found := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		var event struct {
+			Type string `json:"type"`
+			Part struct {
+				Tokens *struct {
+					Input  int `json:"input"`
+					Output int `json:"output"`
+				} `json:"tokens"`
+			} `json:"part"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if event.Type == "step_finish" && event.Part.Tokens != nil {
+			usage.InputTokens += event.Part.Tokens.Input
+			usage.OutputTokens += event.Part.Tokens.Output
+			found = true
+		}
+	}
+	return usage, found
+}
+
+// Binary returns the executable the CLI runs
+func (e *OpenCodeExecutor) Binary() string {
+	return "opencode"
 }
 
 // Name returns the Executor name
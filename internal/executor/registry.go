@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]func() Executor)
+)
+
+// Register makes an Executor available under name. New CLIs are supported by
+// implementing Executor and registering it from an init function.
+func Register(name string, factory func() Executor) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic("executor: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// Lookup returns a new Executor for the CLI name, or false if none is
+// registered
+func Lookup(name string) (Executor, bool) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
+
+// Names returns the registered CLI names, sorted
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
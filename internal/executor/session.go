@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SessionParser extracts the session ID from the output of a CLI
+type SessionParser interface {
+	// ParseSessionID returns the session ID found in output, or false if
+	// there is none
+	ParseSessionID(output string) (string, bool)
+}
+
+// SessionFinder is implemented by executors that can look up a session in
+// the CLI's own session store, used when no session ID could be parsed
+type SessionFinder interface {
+	// FindSession returns the ID of the session written for workDir since
+	// the given time, or false unless there is exactly one
+	FindSession(workDir string, since time.Time) (string, bool)
+}
+
+// RegexSessionParser finds the session ID with the first capture group of
+// a regular expression
+type RegexSessionParser struct {
+	Pattern *regexp.Regexp
+}
+
+// ParseSessionID implements SessionParser
+func (p RegexSessionParser) ParseSessionID(output string) (string, bool) {
+	match := p.Pattern.FindStringSubmatch(output)
+	if len(match) < 2 || match[1] == "" {
+		return "", false
+	}
+	return match[1], true
+}
+
+// JSONSessionParser reads the session ID from a field of JSON lines output.
+// Path is a dot-separated list of object keys, e.g. "session_id" or
+// "part.sessionID". The last line containing the field wins.
+type JSONSessionParser struct {
+	Path string
+}
+
+// ParseSessionID implements SessionParser
+func (p JSONSessionParser) ParseSessionID(output string) (string, bool) {
+	keys := strings.Split(p.Path, ".")
+	sessionID := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		var value interface{}
+		if err := json.Unmarshal([]byte(line), &value); err != nil {
+			continue
+		}
+		for _, key := range keys {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = object[key]
+		}
+		if id, ok := value.(string); ok && id != "" {
+			sessionID = id
+		}
+	}
+
+	return sessionID, sessionID != ""
+}
@@ -0,0 +1,41 @@
+package executor
+
+import "testing"
+
+// claudeOutput is stream-json output captured from claude -p
+const claudeOutput = `{"type":"system","subtype":"init","cwd":"/home/dev/project","session_id":"0f6c5c7e-3b7d-4a41-9e0f-51c2a8d1f9b2","tools":["Task","Bash","Glob","Grep","Read","Edit","Write"],"model":"claude-opus-4-6","permissionMode":"default","apiKeySource":"none"}
+{"type":"assistant","message":{"id":"msg_01","type":"message","role":"assistant","model":"claude-opus-4-6","content":[{"type":"text","text":"The tests pass."}],"stop_reason":null,"usage":{"input_tokens":4,"output_tokens":8}},"parent_tool_use_id":null,"session_id":"0f6c5c7e-3b7d-4a41-9e0f-51c2a8d1f9b2"}
+{"type":"result","subtype":"success","is_error":false,"duration_ms":2150,"num_turns":1,"result":"The tests pass.","session_id":"0f6c5c7e-3b7d-4a41-9e0f-51c2a8d1f9b2","total_cost_usd":0.0123,"usage":{"input_tokens":4,"cache_creation_input_tokens":1200,"cache_read_input_tokens":13000,"output_tokens":8}}
+`
+
+// openCodeOutput is JSON output captured from opencode run --format json
+const openCodeOutput = `{"type":"step_start","timestamp":1760000000000,"sessionID":"ses_6a1f3c2e5ffeYb0Qk2Ztq1Rw8M","part":{"id":"prt_01","sessionID":"ses_6a1f3c2e5ffeYb0Qk2Ztq1Rw8M","messageID":"msg_01","type":"step-start"}}
+{"type":"text","timestamp":1760000001000,"sessionID":"ses_6a1f3c2e5ffeYb0Qk2Ztq1Rw8M","part":{"id":"prt_02","sessionID":"ses_6a1f3c2e5ffeYb0Qk2Ztq1Rw8M","messageID":"msg_01","type":"text","text":"The tests pass."}}
+{"type":"step_finish","timestamp":1760000001500,"sessionID":"ses_6a1f3c2e5ffeYb0Qk2Ztq1Rw8M","part":{"id":"prt_03","sessionID":"ses_6a1f3c2e5ffeYb0Qk2Ztq1Rw8M","messageID":"msg_01","type":"step-finish","tokens":{"input":12,"output":5,"reasoning":0,"cache":{"read":0,"write":0}},"cost":0}}
+`
+
+func TestSessionParsers(t *testing.T) {
+	tests := []struct {
+		name   string
+		cli    string
+		output string
+		want   string
+		wantOK bool
+	}{
+		{name: "claude", cli: "claude", output: claudeOutput, want: "0f6c5c7e-3b7d-4a41-9e0f-51c2a8d1f9b2", wantOK: true},
+		{name: "claude error", cli: "claude", output: "Error: Invalid API key · Please run /login\n"},
+		{name: "claude failed run", cli: "claude", output: `{"type":"result","subtype":"error_during_execution","is_error":true}`},
+		{name: "opencode", cli: "opencode", output: openCodeOutput, want: "ses_6a1f3c2e5ffeYb0Qk2Ztq1Rw8M", wantOK: true},
+		{name: "opencode error", cli: "opencode", output: "Error: Model not found: anthropic/unknown\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exec, _ := Lookup(tt.cli)
+			got, ok := exec.SessionParser().ParseSessionID(tt.output)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ParseSessionID = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -1,47 +1,213 @@
 package session
 
 import (
+	"strings"
 	"sync"
+	"time"
 )
 
-// Manager manages session IDs per chat_id
-type Manager struct {
-	sessions map[int64]string
+// maxHistory is how many sessions are remembered per key for /sessions
+const maxHistory = 10
+
+// Record is a session a key used
+type Record struct {
+	ID       string    `json:"id"`
+	LastUsed time.Time `json:"last_used"` // When it last became the active session
+}
+
+// Manager manages session IDs per conversation, identified by a key such as
+// a chat_id. It also remembers the session each conversation had before the
+// last reset or change, so it can be restored, and the latest sessions it
+// used.
+type Manager[K comparable] struct {
+	sessions map[K]string
+	previous map[K]string
+	history  map[K][]Record // Most recently used first
 	mu       sync.RWMutex
 }
 
 // NewManager creates a new session manager
-func NewManager() *Manager {
-	return &Manager{
-		sessions: make(map[int64]string),
+func NewManager[K comparable]() *Manager[K] {
+	return &Manager[K]{
+		sessions: make(map[K]string),
+		previous: make(map[K]string),
+		history:  make(map[K][]Record),
+	}
+}
+
+// remember records sessionID as the key's most recently used session.
+// The caller must hold m.mu.
+func (m *Manager[K]) remember(key K, sessionID string) {
+	if sessionID == "" {
+		return
 	}
+	records := []Record{{ID: sessionID, LastUsed: time.Now()}}
+	for _, record := range m.history[key] {
+		if record.ID != sessionID && len(records) < maxHistory {
+			records = append(records, record)
+		}
+	}
+	m.history[key] = records
 }
 
-// Get returns the session ID for a chat_id
-func (m *Manager) Get(chatID int64) string {
+// Get returns the session ID for a key
+func (m *Manager[K]) Get(key K) string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	return m.sessions[chatID]
+	return m.sessions[key]
+}
+
+// Set saves the session ID for a key
+func (m *Manager[K]) Set(key K, sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[key] = sessionID
+	m.remember(key, sessionID)
+}
+
+// CompareAndSet saves the session ID for a key only if the current
+// session ID is still old, reporting whether it was saved. A non-empty old
+// session is kept as the previous one.
+func (m *Manager[K]) CompareAndSet(key K, old, sessionID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessions[key] != old {
+		return false
+	}
+	if old != "" {
+		m.previous[key] = old
+	}
+	m.sessions[key] = sessionID
+	m.remember(key, sessionID)
+	return true
+}
+
+// Discard removes the session for a key, keeping it as the previous one
+func (m *Manager[K]) Discard(key K) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current := m.sessions[key]; current != "" {
+		m.previous[key] = current
+	}
+	delete(m.sessions, key)
+}
+
+// Undo swaps the session of a key with the previous one and returns the
+// restored session ID, or false if there is none
+func (m *Manager[K]) Undo(key K) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	previous := m.previous[key]
+	if previous == "" {
+		return "", false
+	}
+
+	if current := m.sessions[key]; current != "" {
+		m.previous[key] = current
+	} else {
+		delete(m.previous, key)
+	}
+	m.sessions[key] = previous
+	m.remember(key, previous)
+	return previous, true
 }
 
-// Set saves the session ID for a chat_id
-func (m *Manager) Set(chatID int64, sessionID string) {
+// Resume makes a session from the key's history the active one, keeping
+// the current session as the previous one. id may be a unique prefix of the
+// session ID. It returns the full session ID, or false if no known session
+// matches.
+func (m *Manager[K]) Resume(key K, id string) (string, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	m.sessions[chatID] = sessionID
+
+	match := ""
+	for _, record := range m.history[key] {
+		if record.ID == id {
+			match = id
+			break
+		}
+		if strings.HasPrefix(record.ID, id) {
+			if match != "" {
+				return "", false // Ambiguous prefix
+			}
+			match = record.ID
+		}
+	}
+	if match == "" {
+		return "", false
+	}
+
+	if current := m.sessions[key]; current != "" && current != match {
+		m.previous[key] = current
+	}
+	m.sessions[key] = match
+	m.remember(key, match)
+	return match, true
 }
 
-// Delete removes the session for a chat_id
-func (m *Manager) Delete(chatID int64) {
+// History returns the sessions the key used, most recent first
+func (m *Manager[K]) History(key K) []Record {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Record(nil), m.history[key]...)
+}
+
+// Delete removes the session, the previous session and the session history
+// for a key
+func (m *Manager[K]) Delete(key K) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.sessions, chatID)
+	delete(m.sessions, key)
+	delete(m.previous, key)
+	delete(m.history, key)
 }
 
 // Exists checks if a session exists
-func (m *Manager) Exists(chatID int64) bool {
+func (m *Manager[K]) Exists(key K) bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
-	_, exists := m.sessions[chatID]
+	_, exists := m.sessions[key]
 	return exists
 }
+
+// All returns a copy of all sessions
+func (m *Manager[K]) All() map[K]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sessions := make(map[K]string, len(m.sessions))
+	for key, sessionID := range m.sessions {
+		sessions[key] = sessionID
+	}
+	return sessions
+}
+
+// Replace replaces all sessions with the given ones
+func (m *Manager[K]) Replace(sessions map[K]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions = make(map[K]string, len(sessions))
+	for key, sessionID := range sessions {
+		m.sessions[key] = sessionID
+	}
+}
+
+// AllHistory returns a copy of the session history of all keys
+func (m *Manager[K]) AllHistory() map[K][]Record {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	history := make(map[K][]Record, len(m.history))
+	for key, records := range m.history {
+		history[key] = append([]Record(nil), records...)
+	}
+	return history
+}
+
+// ReplaceHistory replaces the session history of all keys
+func (m *Manager[K]) ReplaceHistory(history map[K][]Record) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.history = make(map[K][]Record, len(history))
+	for key, records := range history {
+		m.history[key] = append([]Record(nil), records...)
+	}
+}